@@ -0,0 +1,151 @@
+package geometry
+
+import (
+	"math"
+
+	"golang.org/x/image/math/f64"
+)
+
+// An Affine is a 2D affine transformation matrix, stored in row-major order
+// with the implicit bottom row [0 0 1]. The point (x, y) is mapped to
+//
+//	(m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5])
+//
+// The memory layout matches f64.Aff3 as used by golang.org/x/image/draw.
+type Affine [6]float64
+
+// Identity is the identity transformation.
+var Identity = Affine{
+	1, 0, 0,
+	0, 1, 0,
+}
+
+// FromAff3 returns the affine transformation of the given f64.Aff3 matrix.
+func FromAff3(m f64.Aff3) Affine {
+	return Affine(m)
+}
+
+// Aff3 returns m as an f64.Aff3 matrix, as used by golang.org/x/image/draw.
+func (m Affine) Aff3() f64.Aff3 {
+	return f64.Aff3(m)
+}
+
+// Compose returns the transformation that applies m followed by n.
+func (m Affine) Compose(n Affine) Affine {
+	return Affine{
+		n[0]*m[0] + n[1]*m[3],
+		n[0]*m[1] + n[1]*m[4],
+		n[0]*m[2] + n[1]*m[5] + n[2],
+		n[3]*m[0] + n[4]*m[3],
+		n[3]*m[1] + n[4]*m[4],
+		n[3]*m[2] + n[4]*m[5] + n[5],
+	}
+}
+
+// Translate returns the transformation that applies m followed by a
+// translation by d.
+func (m Affine) Translate(d Point) Affine {
+	return m.Compose(Affine{
+		1, 0, d.X,
+		0, 1, d.Y,
+	})
+}
+
+// Scale returns the transformation that applies m followed by a scaling by sx
+// and sy along the x- and y-axes respectively.
+func (m Affine) Scale(sx, sy float64) Affine {
+	return m.Compose(Affine{
+		sx, 0, 0,
+		0, sy, 0,
+	})
+}
+
+// Rotate returns the transformation that applies m followed by a rotation by
+// theta radians around the origin. As the y-axis increases downwards, positive
+// angles rotate clockwise on screen.
+func (m Affine) Rotate(theta float64) Affine {
+	sin, cos := math.Sincos(theta)
+	return m.Compose(Affine{
+		cos, -sin, 0,
+		sin, cos, 0,
+	})
+}
+
+// Shear returns the transformation that applies m followed by a shear by shx
+// along the x-axis and shy along the y-axis.
+func (m Affine) Shear(shx, shy float64) Affine {
+	return m.Compose(Affine{
+		1, shx, 0,
+		shy, 1, 0,
+	})
+}
+
+// Det returns the determinant of the linear part of m.
+func (m Affine) Det() float64 {
+	return m[0]*m[4] - m[1]*m[3]
+}
+
+// Invert returns the inverse transformation of m. The boolean result is false
+// if m is not invertible.
+func (m Affine) Invert() (Affine, bool) {
+	det := m.Det()
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Affine{}, false
+	}
+	a := m[4] / det
+	b := -m[1] / det
+	d := -m[3] / det
+	e := m[0] / det
+	return Affine{
+		a, b, -(a*m[2] + b*m[5]),
+		d, e, -(d*m[2] + e*m[5]),
+	}, true
+}
+
+// Apply returns the point p transformed by m.
+func (m Affine) Apply(p Point) Point {
+	return Point{
+		m[0]*p.X + m[1]*p.Y + m[2],
+		m[3]*p.X + m[4]*p.Y + m[5],
+	}
+}
+
+// ApplyVector returns the vector v transformed by the linear part of m, i.e.
+// ignoring translation.
+func (m Affine) ApplyVector(v Point) Point {
+	return Point{
+		m[0]*v.X + m[1]*v.Y,
+		m[3]*v.X + m[4]*v.Y,
+	}
+}
+
+// ApplyRect returns the bounding box of the rectangle r transformed by m.
+func (m Affine) ApplyRect(r Rectangle) Rectangle {
+	corners := [4]Point{
+		m.Apply(r.Min),
+		m.Apply(Point{r.Max.X, r.Min.Y}),
+		m.Apply(r.Max),
+		m.Apply(Point{r.Min.X, r.Max.Y}),
+	}
+	return boundingBox(corners[:])
+}
+
+// IsIdentity reports whether m is the identity transformation.
+func (m Affine) IsIdentity() bool {
+	return m == Identity
+}
+
+// boundingBox returns the smallest rectangle containing all given points.
+func boundingBox(ps []Point) Rectangle {
+	if len(ps) == 0 {
+		return ZR
+	}
+	r := Rectangle{ps[0], ps[0]}
+	for _, p := range ps[1:] {
+		r.Min.X = math.Min(r.Min.X, p.X)
+		r.Min.Y = math.Min(r.Min.Y, p.Y)
+		r.Max.X = math.Max(r.Max.X, p.X)
+		r.Max.Y = math.Max(r.Max.Y, p.Y)
+	}
+	return r
+}
@@ -0,0 +1,40 @@
+package geometry_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestAffineInvert(t *testing.T) {
+	golden := []geometry.Affine{
+		geometry.Identity.Translate(geometry.Pt(3, -2)),
+		geometry.Identity.Scale(2, 0.5).Rotate(math.Pi / 3),
+		geometry.Identity.Shear(0.25, 0).Translate(geometry.Pt(1, 1)).Rotate(-1),
+	}
+	p := geometry.Pt(7, 11)
+	for _, m := range golden {
+		inv, ok := m.Invert()
+		if !ok {
+			t.Errorf("unable to invert %v", m)
+			continue
+		}
+		got := inv.Apply(m.Apply(p))
+		if got.Distance(p) > 1e-9 {
+			t.Errorf("round trip mismatch for %v; expected %v, got %v", m, p, got)
+		}
+	}
+	if _, ok := geometry.Identity.Scale(0, 1).Invert(); ok {
+		t.Errorf("expected singular matrix to not be invertible")
+	}
+}
+
+func TestAffineApplyRect(t *testing.T) {
+	m := geometry.Identity.Rotate(math.Pi / 2)
+	got := m.ApplyRect(geometry.Rect(0, 0, 2, 1))
+	want := geometry.Rect(-1, 0, 0, 2)
+	if got.Min.Distance(want.Min) > 1e-9 || got.Max.Distance(want.Max) > 1e-9 {
+		t.Errorf("bounding box mismatch; expected %v, got %v", want, got)
+	}
+}
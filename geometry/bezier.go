@@ -0,0 +1,232 @@
+package geometry
+
+import (
+	"math"
+)
+
+// maxFlattenDepth is the maximum recursion depth of adaptive curve subdivision.
+const maxFlattenDepth = 16
+
+// A QuadBezier is a quadratic Bézier curve from P0 to P2 with control point P1.
+type QuadBezier struct {
+	P0, P1, P2 Point
+}
+
+// Point returns the point on the curve at parameter t in [0, 1].
+func (c QuadBezier) Point(t float64) Point {
+	u := 1 - t
+	return c.P0.Mul(u * u).Add(c.P1.Mul(2 * u * t)).Add(c.P2.Mul(t * t))
+}
+
+// Derivative returns the tangent vector of the curve at parameter t in [0, 1].
+func (c QuadBezier) Derivative(t float64) Point {
+	return c.P1.Sub(c.P0).Mul(2 * (1 - t)).Add(c.P2.Sub(c.P1).Mul(2 * t))
+}
+
+// Split splits the curve at parameter t in [0, 1] using de Casteljau's
+// algorithm, and returns the two halves.
+func (c QuadBezier) Split(t float64) (QuadBezier, QuadBezier) {
+	p01 := c.P0.Lerp(c.P1, t)
+	p12 := c.P1.Lerp(c.P2, t)
+	p := p01.Lerp(p12, t)
+	return QuadBezier{c.P0, p01, p}, QuadBezier{p, p12, c.P2}
+}
+
+// Bounds returns the tight bounding box of the curve.
+func (c QuadBezier) Bounds() Rectangle {
+	ps := []Point{c.P0, c.P2}
+	for _, t := range quadExtrema(c.P0.X, c.P1.X, c.P2.X) {
+		ps = append(ps, c.Point(t))
+	}
+	for _, t := range quadExtrema(c.P0.Y, c.P1.Y, c.P2.Y) {
+		ps = append(ps, c.Point(t))
+	}
+	return boundingBox(ps)
+}
+
+// Cubic returns the curve elevated to an equivalent cubic Bézier curve.
+func (c QuadBezier) Cubic() CubicBezier {
+	return CubicBezier{
+		P0: c.P0,
+		P1: c.P0.Lerp(c.P1, 2.0/3),
+		P2: c.P2.Lerp(c.P1, 2.0/3),
+		P3: c.P2,
+	}
+}
+
+// Transform returns the curve transformed by m.
+func (c QuadBezier) Transform(m Affine) QuadBezier {
+	return QuadBezier{m.Apply(c.P0), m.Apply(c.P1), m.Apply(c.P2)}
+}
+
+// Len returns an approximation of the arc length of the curve, accurate to
+// within the given tolerance.
+func (c QuadBezier) Len(tolerance float64) float64 {
+	return c.length(tolerance, 0)
+}
+
+// length returns the arc length of the curve using Gravesen's method with
+// adaptive subdivision.
+func (c QuadBezier) length(tolerance float64, depth int) float64 {
+	chord := c.P0.Distance(c.P2)
+	poly := c.P0.Distance(c.P1) + c.P1.Distance(c.P2)
+	if poly-chord <= tolerance || depth >= maxFlattenDepth {
+		return (2*chord + poly) / 3
+	}
+	a, b := c.Split(0.5)
+	return a.length(tolerance/2, depth+1) + b.length(tolerance/2, depth+1)
+}
+
+// Flatten approximates the curve by a polyline deviating at most tolerance from
+// the curve.
+func (c QuadBezier) Flatten(tolerance float64) Polyline {
+	pl := Polyline{c.P0}
+	return c.flatten(pl, tolerance, 0)
+}
+
+// flatten appends the end points of the line segments approximating the curve
+// to pl, using adaptive subdivision.
+func (c QuadBezier) flatten(pl Polyline, tolerance float64, depth int) Polyline {
+	// The curve deviates from its chord by at most half the distance of the
+	// control point from the chord.
+	if Seg(c.P0, c.P2).Distance(c.P1)/2 <= tolerance || depth >= maxFlattenDepth {
+		return append(pl, c.P2)
+	}
+	a, b := c.Split(0.5)
+	pl = a.flatten(pl, tolerance, depth+1)
+	return b.flatten(pl, tolerance, depth+1)
+}
+
+// A CubicBezier is a cubic Bézier curve from P0 to P3 with control points P1
+// and P2.
+type CubicBezier struct {
+	P0, P1, P2, P3 Point
+}
+
+// Point returns the point on the curve at parameter t in [0, 1].
+func (c CubicBezier) Point(t float64) Point {
+	u := 1 - t
+	return c.P0.Mul(u * u * u).
+		Add(c.P1.Mul(3 * u * u * t)).
+		Add(c.P2.Mul(3 * u * t * t)).
+		Add(c.P3.Mul(t * t * t))
+}
+
+// Derivative returns the tangent vector of the curve at parameter t in [0, 1].
+func (c CubicBezier) Derivative(t float64) Point {
+	u := 1 - t
+	return c.P1.Sub(c.P0).Mul(3 * u * u).
+		Add(c.P2.Sub(c.P1).Mul(6 * u * t)).
+		Add(c.P3.Sub(c.P2).Mul(3 * t * t))
+}
+
+// Split splits the curve at parameter t in [0, 1] using de Casteljau's
+// algorithm, and returns the two halves.
+func (c CubicBezier) Split(t float64) (CubicBezier, CubicBezier) {
+	p01 := c.P0.Lerp(c.P1, t)
+	p12 := c.P1.Lerp(c.P2, t)
+	p23 := c.P2.Lerp(c.P3, t)
+	p012 := p01.Lerp(p12, t)
+	p123 := p12.Lerp(p23, t)
+	p := p012.Lerp(p123, t)
+	return CubicBezier{c.P0, p01, p012, p}, CubicBezier{p, p123, p23, c.P3}
+}
+
+// Bounds returns the tight bounding box of the curve.
+func (c CubicBezier) Bounds() Rectangle {
+	ps := []Point{c.P0, c.P3}
+	for _, t := range cubicExtrema(c.P0.X, c.P1.X, c.P2.X, c.P3.X) {
+		ps = append(ps, c.Point(t))
+	}
+	for _, t := range cubicExtrema(c.P0.Y, c.P1.Y, c.P2.Y, c.P3.Y) {
+		ps = append(ps, c.Point(t))
+	}
+	return boundingBox(ps)
+}
+
+// Transform returns the curve transformed by m.
+func (c CubicBezier) Transform(m Affine) CubicBezier {
+	return CubicBezier{m.Apply(c.P0), m.Apply(c.P1), m.Apply(c.P2), m.Apply(c.P3)}
+}
+
+// Len returns an approximation of the arc length of the curve, accurate to
+// within the given tolerance.
+func (c CubicBezier) Len(tolerance float64) float64 {
+	return c.length(tolerance, 0)
+}
+
+// length returns the arc length of the curve using Gravesen's method with
+// adaptive subdivision.
+func (c CubicBezier) length(tolerance float64, depth int) float64 {
+	chord := c.P0.Distance(c.P3)
+	poly := c.P0.Distance(c.P1) + c.P1.Distance(c.P2) + c.P2.Distance(c.P3)
+	if poly-chord <= tolerance || depth >= maxFlattenDepth {
+		return (chord + poly) / 2
+	}
+	a, b := c.Split(0.5)
+	return a.length(tolerance/2, depth+1) + b.length(tolerance/2, depth+1)
+}
+
+// Flatten approximates the curve by a polyline deviating at most tolerance from
+// the curve.
+func (c CubicBezier) Flatten(tolerance float64) Polyline {
+	pl := Polyline{c.P0}
+	return c.flatten(pl, tolerance, 0)
+}
+
+// flatten appends the end points of the line segments approximating the curve
+// to pl, using adaptive subdivision.
+func (c CubicBezier) flatten(pl Polyline, tolerance float64, depth int) Polyline {
+	// The curve deviates from its chord by at most 3/4 of the maximum distance
+	// of the control points from the chord.
+	chord := Seg(c.P0, c.P3)
+	d := math.Max(chord.Distance(c.P1), chord.Distance(c.P2))
+	if d*3/4 <= tolerance || depth >= maxFlattenDepth {
+		return append(pl, c.P3)
+	}
+	a, b := c.Split(0.5)
+	pl = a.flatten(pl, tolerance, depth+1)
+	return b.flatten(pl, tolerance, depth+1)
+}
+
+// quadExtrema returns the parameters in (0, 1) of the extrema of the quadratic
+// Bézier polynomial with the given coefficients.
+func quadExtrema(p0, p1, p2 float64) []float64 {
+	denom := p0 - 2*p1 + p2
+	if denom == 0 {
+		return nil
+	}
+	t := (p0 - p1) / denom
+	if t <= 0 || t >= 1 {
+		return nil
+	}
+	return []float64{t}
+}
+
+// cubicExtrema returns the parameters in (0, 1) of the extrema of the cubic
+// Bézier polynomial with the given coefficients.
+func cubicExtrema(p0, p1, p2, p3 float64) []float64 {
+	// Derivative: a*t^2 + b*t + c.
+	a := 3 * (-p0 + 3*p1 - 3*p2 + p3)
+	b := 6 * (p0 - 2*p1 + p2)
+	c := 3 * (p1 - p0)
+	var roots []float64
+	if a == 0 {
+		if b != 0 {
+			roots = append(roots, -c/b)
+		}
+	} else {
+		disc := b*b - 4*a*c
+		if disc >= 0 {
+			sq := math.Sqrt(disc)
+			roots = append(roots, (-b+sq)/(2*a), (-b-sq)/(2*a))
+		}
+	}
+	var ts []float64
+	for _, t := range roots {
+		if t > 0 && t < 1 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
@@ -0,0 +1,70 @@
+package geometry_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestCubicBezier(t *testing.T) {
+	// Quarter circle approximation of radius 100.
+	const k = 0.5522847498
+	c := geometry.CubicBezier{
+		P0: geometry.Pt(100, 0),
+		P1: geometry.Pt(100, 100*k),
+		P2: geometry.Pt(100*k, 100),
+		P3: geometry.Pt(0, 100),
+	}
+	if got, want := c.Len(1e-6), 100*math.Pi/2; math.Abs(got-want) > 0.1 {
+		t.Errorf("arc length mismatch; expected %v, got %v", want, got)
+	}
+	const tolerance = 0.1
+	pl := c.Flatten(tolerance)
+	if pl[0] != c.P0 || pl[len(pl)-1] != c.P3 {
+		t.Errorf("flattened end points mismatch; expected %v and %v, got %v and %v", c.P0, c.P3, pl[0], pl[len(pl)-1])
+	}
+	for _, p := range pl {
+		if d := math.Abs(p.Len() - 100); d > 0.05 {
+			t.Errorf("flattened point %v deviates %v from circle", p, d)
+		}
+	}
+	for _, seg := range pl.Segments() {
+		mid := seg.A.Lerp(seg.B, 0.5)
+		if d := 100 - mid.Len(); d > tolerance+0.05 {
+			t.Errorf("flattened segment %v deviates %v from curve", seg, d)
+		}
+	}
+	a, b := c.Split(0.3)
+	if got, want := a.P3, c.Point(0.3); got.Distance(want) > 1e-9 || b.P0 != a.P3 {
+		t.Errorf("split point mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestQuadBezierBounds(t *testing.T) {
+	c := geometry.QuadBezier{
+		P0: geometry.Pt(0, 0),
+		P1: geometry.Pt(50, 100),
+		P2: geometry.Pt(100, 0),
+	}
+	want := geometry.Rect(0, 0, 100, 50)
+	if got := c.Bounds(); got != want {
+		t.Errorf("bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestPathSVG(t *testing.T) {
+	path := &geometry.Path{}
+	path.MoveTo(geometry.Pt(0, 0))
+	path.LineTo(geometry.Pt(10, 0))
+	path.QuadTo(geometry.Pt(10, 10), geometry.Pt(0, 10))
+	path.Close()
+	want := "M0 0L10 0Q10 10 0 10Z"
+	if got := path.SVG(); got != want {
+		t.Errorf("SVG path data mismatch; expected %q, got %q", want, got)
+	}
+	pls := path.Flatten(0.5)
+	if len(pls) != 1 || pls[0][len(pls[0])-1] != geometry.Pt(0, 0) {
+		t.Errorf("expected single closed polyline, got %v", pls)
+	}
+}
@@ -1,16 +1,49 @@
 // Package geometry implements basic geometric types and operations.
+//
+// The Point and Rectangle types are floating-point counterparts of image.Point
+// and image.Rectangle, and provide the same method set.
 package geometry
 
+import (
+	"image"
+	"math"
+	"strconv"
+)
+
 // A Rectangle contains the points with Min.X <= X < Max.X, Min.Y <= Y < Max.Y.
+// It is well-formed if Min.X <= Max.X and likewise for Y. Points are always
+// well-formed. A rectangle's methods always return well-formed outputs for
+// well-formed inputs.
 type Rectangle struct {
 	Min, Max Point
 }
 
-// Rect is shorthand for Rectangle{Pt(x0, y0), Pt(x1, y1)}.
+// ZR is the zero Rectangle.
+var ZR Rectangle
+
+// Rect is shorthand for Rectangle{Pt(x0, y0), Pt(x1, y1)}. The returned
+// rectangle has minimum and maximum coordinates swapped if necessary so that it
+// is well-formed.
 func Rect(x0, y0, x1, y1 float64) Rectangle {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
 	return Rectangle{Pt(x0, y0), Pt(x1, y1)}
 }
 
+// FromImageRect returns the floating-point counterpart of r.
+func FromImageRect(r image.Rectangle) Rectangle {
+	return Rectangle{FromImagePoint(r.Min), FromImagePoint(r.Max)}
+}
+
+// String returns a string representation of r like "(3,4)-(6,5)".
+func (r Rectangle) String() string {
+	return r.Min.String() + "-" + r.Max.String()
+}
+
 // Dx returns r's width.
 func (r Rectangle) Dx() float64 {
 	return r.Max.X - r.Min.X
@@ -21,12 +54,336 @@ func (r Rectangle) Dy() float64 {
 	return r.Max.Y - r.Min.Y
 }
 
+// Size returns r's width and height.
+func (r Rectangle) Size() Point {
+	return Point{r.Max.X - r.Min.X, r.Max.Y - r.Min.Y}
+}
+
+// Center returns the center point of r.
+func (r Rectangle) Center() Point {
+	return Point{(r.Min.X + r.Max.X) / 2, (r.Min.Y + r.Max.Y) / 2}
+}
+
+// Area returns the area of r, or 0 if r is empty.
+func (r Rectangle) Area() float64 {
+	if r.Empty() {
+		return 0
+	}
+	return r.Dx() * r.Dy()
+}
+
+// Add returns the rectangle r translated by p.
+func (r Rectangle) Add(p Point) Rectangle {
+	return Rectangle{
+		Point{r.Min.X + p.X, r.Min.Y + p.Y},
+		Point{r.Max.X + p.X, r.Max.Y + p.Y},
+	}
+}
+
+// Sub returns the rectangle r translated by -p.
+func (r Rectangle) Sub(p Point) Rectangle {
+	return Rectangle{
+		Point{r.Min.X - p.X, r.Min.Y - p.Y},
+		Point{r.Max.X - p.X, r.Max.Y - p.Y},
+	}
+}
+
+// Inset returns the rectangle r inset by n, which may be negative. If either of
+// r's dimensions is less than 2*n then an empty rectangle near the center of r
+// will be returned.
+func (r Rectangle) Inset(n float64) Rectangle {
+	if r.Dx() < 2*n {
+		r.Min.X = (r.Min.X + r.Max.X) / 2
+		r.Max.X = r.Min.X
+	} else {
+		r.Min.X += n
+		r.Max.X -= n
+	}
+	if r.Dy() < 2*n {
+		r.Min.Y = (r.Min.Y + r.Max.Y) / 2
+		r.Max.Y = r.Min.Y
+	} else {
+		r.Min.Y += n
+		r.Max.Y -= n
+	}
+	return r
+}
+
+// Intersect returns the largest rectangle contained by both r and s. If the two
+// rectangles do not overlap then the zero rectangle will be returned.
+func (r Rectangle) Intersect(s Rectangle) Rectangle {
+	if r.Min.X < s.Min.X {
+		r.Min.X = s.Min.X
+	}
+	if r.Min.Y < s.Min.Y {
+		r.Min.Y = s.Min.Y
+	}
+	if r.Max.X > s.Max.X {
+		r.Max.X = s.Max.X
+	}
+	if r.Max.Y > s.Max.Y {
+		r.Max.Y = s.Max.Y
+	}
+	// Letting r0 and s0 be the values of r and s at the time that the method is
+	// called, this next line is equivalent to:
+	//
+	// if max(r0.Min.X, s0.Min.X) >= min(r0.Max.X, s0.Max.X) || likewise for Y { etc }
+	if r.Empty() {
+		return ZR
+	}
+	return r
+}
+
+// Union returns the smallest rectangle that contains both r and s.
+func (r Rectangle) Union(s Rectangle) Rectangle {
+	if r.Empty() {
+		return s
+	}
+	if s.Empty() {
+		return r
+	}
+	if r.Min.X > s.Min.X {
+		r.Min.X = s.Min.X
+	}
+	if r.Min.Y > s.Min.Y {
+		r.Min.Y = s.Min.Y
+	}
+	if r.Max.X < s.Max.X {
+		r.Max.X = s.Max.X
+	}
+	if r.Max.Y < s.Max.Y {
+		r.Max.Y = s.Max.Y
+	}
+	return r
+}
+
+// Empty reports whether the rectangle contains no points.
+func (r Rectangle) Empty() bool {
+	return r.Min.X >= r.Max.X || r.Min.Y >= r.Max.Y
+}
+
+// Eq reports whether r and s contain the same set of points. All empty
+// rectangles are considered equal.
+func (r Rectangle) Eq(s Rectangle) bool {
+	return r == s || r.Empty() && s.Empty()
+}
+
+// Overlaps reports whether r and s have a non-empty intersection.
+func (r Rectangle) Overlaps(s Rectangle) bool {
+	return !r.Empty() && !s.Empty() &&
+		r.Min.X < s.Max.X && s.Min.X < r.Max.X &&
+		r.Min.Y < s.Max.Y && s.Min.Y < r.Max.Y
+}
+
+// In reports whether every point in r is in s.
+func (r Rectangle) In(s Rectangle) bool {
+	if r.Empty() {
+		return true
+	}
+	// Note that r.Max is an exclusive bound for r, so that r.In(s) does not
+	// require that r.Max.In(s).
+	return s.Min.X <= r.Min.X && r.Max.X <= s.Max.X &&
+		s.Min.Y <= r.Min.Y && r.Max.Y <= s.Max.Y
+}
+
+// Canon returns the canonical version of r. The returned rectangle has minimum
+// and maximum coordinates swapped if necessary so that it is well-formed.
+func (r Rectangle) Canon() Rectangle {
+	if r.Max.X < r.Min.X {
+		r.Min.X, r.Max.X = r.Max.X, r.Min.X
+	}
+	if r.Max.Y < r.Min.Y {
+		r.Min.Y, r.Max.Y = r.Max.Y, r.Min.Y
+	}
+	return r
+}
+
+// Lerp returns the rectangle linearly interpolated between r and s by t, where
+// t = 0 gives r and t = 1 gives s.
+func (r Rectangle) Lerp(s Rectangle, t float64) Rectangle {
+	return Rectangle{r.Min.Lerp(s.Min, t), r.Max.Lerp(s.Max, t)}
+}
+
+// Image returns the integer counterpart of r, rounding its coordinates
+// according to mode. If r contains no whole pixel when rounded inwards, the
+// zero rectangle is returned.
+func (r Rectangle) Image(mode RoundMode) image.Rectangle {
+	switch mode {
+	case RoundOut:
+		return image.Rectangle{
+			Min: r.Min.Image(RoundDown),
+			Max: r.Max.Image(RoundUp),
+		}
+	case RoundIn:
+		ir := image.Rectangle{
+			Min: r.Min.Image(RoundUp),
+			Max: r.Max.Image(RoundDown),
+		}
+		if ir.Min.X > ir.Max.X || ir.Min.Y > ir.Max.Y {
+			// Like image.Rectangle.Intersect, return the zero rectangle rather
+			// than a malformed one.
+			return image.Rectangle{}
+		}
+		return ir
+	default:
+		return image.Rectangle{
+			Min: r.Min.Image(mode),
+			Max: r.Max.Image(mode),
+		}
+	}
+}
+
 // A Point is an X, Y coordinate pair. The axes increase right and down.
 type Point struct {
 	X, Y float64
 }
 
+// ZP is the zero Point.
+var ZP Point
+
 // Pt is shorthand for Point{X, Y}.
 func Pt(x, y float64) Point {
 	return Point{x, y}
 }
+
+// FromImagePoint returns the floating-point counterpart of p.
+func FromImagePoint(p image.Point) Point {
+	return Point{float64(p.X), float64(p.Y)}
+}
+
+// String returns a string representation of p like "(3,4)".
+func (p Point) String() string {
+	return "(" + formatFloat(p.X) + "," + formatFloat(p.Y) + ")"
+}
+
+// Add returns the vector p+q.
+func (p Point) Add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+// Sub returns the vector p-q.
+func (p Point) Sub(q Point) Point {
+	return Point{p.X - q.X, p.Y - q.Y}
+}
+
+// Mul returns the vector p*k.
+func (p Point) Mul(k float64) Point {
+	return Point{p.X * k, p.Y * k}
+}
+
+// Div returns the vector p/k.
+func (p Point) Div(k float64) Point {
+	return Point{p.X / k, p.Y / k}
+}
+
+// In reports whether p is in r.
+func (p Point) In(r Rectangle) bool {
+	return r.Min.X <= p.X && p.X < r.Max.X &&
+		r.Min.Y <= p.Y && p.Y < r.Max.Y
+}
+
+// Mod returns the point q in r such that p.X-q.X is a multiple of r's width
+// and p.Y-q.Y is a multiple of r's height.
+func (p Point) Mod(r Rectangle) Point {
+	w, h := r.Dx(), r.Dy()
+	p = p.Sub(r.Min)
+	p.X = math.Mod(p.X, w)
+	if p.X < 0 {
+		p.X += w
+	}
+	p.Y = math.Mod(p.Y, h)
+	if p.Y < 0 {
+		p.Y += h
+	}
+	return p.Add(r.Min)
+}
+
+// Eq reports whether p and q are equal.
+func (p Point) Eq(q Point) bool {
+	return p == q
+}
+
+// Dot returns the dot product of p and q.
+func (p Point) Dot(q Point) float64 {
+	return p.X*q.X + p.Y*q.Y
+}
+
+// Cross returns the z-component of the cross product of p and q.
+func (p Point) Cross(q Point) float64 {
+	return p.X*q.Y - p.Y*q.X
+}
+
+// Len returns the length of the vector p.
+func (p Point) Len() float64 {
+	return math.Hypot(p.X, p.Y)
+}
+
+// Distance returns the Euclidean distance between p and q.
+func (p Point) Distance(q Point) float64 {
+	return p.Sub(q).Len()
+}
+
+// Normalize returns the unit vector in the direction of p. The zero vector is
+// returned unchanged.
+func (p Point) Normalize() Point {
+	l := p.Len()
+	if l == 0 {
+		return p
+	}
+	return p.Div(l)
+}
+
+// Lerp returns the point linearly interpolated between p and q by t, where
+// t = 0 gives p and t = 1 gives q.
+func (p Point) Lerp(q Point, t float64) Point {
+	return Point{p.X + (q.X-p.X)*t, p.Y + (q.Y-p.Y)*t}
+}
+
+// Image returns the integer counterpart of p, rounding its coordinates
+// according to mode. RoundOut and RoundIn are treated as RoundNearest for
+// points.
+func (p Point) Image(mode RoundMode) image.Point {
+	round := mode.roundFunc()
+	return image.Point{int(round(p.X)), int(round(p.Y))}
+}
+
+// RoundMode specifies how floating-point coordinates are rounded to integer
+// coordinates.
+type RoundMode uint8
+
+// Rounding modes.
+const (
+	// RoundNearest rounds to the nearest integer, rounding half away from zero.
+	RoundNearest RoundMode = iota
+	// RoundDown rounds towards negative infinity.
+	RoundDown
+	// RoundUp rounds towards positive infinity.
+	RoundUp
+	// RoundTrunc rounds towards zero.
+	RoundTrunc
+	// RoundOut rounds rectangles outwards, so that the integer rectangle
+	// contains the floating-point rectangle.
+	RoundOut
+	// RoundIn rounds rectangles inwards, so that the integer rectangle is
+	// contained by the floating-point rectangle.
+	RoundIn
+)
+
+// roundFunc returns the rounding function of the given point rounding mode.
+func (mode RoundMode) roundFunc() func(float64) float64 {
+	switch mode {
+	case RoundDown:
+		return math.Floor
+	case RoundUp:
+		return math.Ceil
+	case RoundTrunc:
+		return math.Trunc
+	default:
+		return math.Round
+	}
+}
+
+// formatFloat returns the shortest string representation of x.
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
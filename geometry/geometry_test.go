@@ -0,0 +1,82 @@
+package geometry_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestRectangleIntersect(t *testing.T) {
+	golden := []struct {
+		r, s geometry.Rectangle
+		want geometry.Rectangle
+	}{
+		{
+			r:    geometry.Rect(0, 0, 10, 10),
+			s:    geometry.Rect(5, 5, 15, 15),
+			want: geometry.Rect(5, 5, 10, 10),
+		},
+		{
+			r:    geometry.Rect(0, 0, 10, 10),
+			s:    geometry.Rect(10, 10, 15, 15),
+			want: geometry.ZR,
+		},
+		{
+			r:    geometry.Rect(0.5, 0.5, 1.5, 1.5),
+			s:    geometry.Rect(0, 1, 2, 2),
+			want: geometry.Rect(0.5, 1, 1.5, 1.5),
+		},
+	}
+	for _, g := range golden {
+		got := g.r.Intersect(g.s)
+		if got != g.want {
+			t.Errorf("%v.Intersect(%v) mismatch; expected %v, got %v", g.r, g.s, g.want, got)
+		}
+	}
+}
+
+func TestRectangleImage(t *testing.T) {
+	r := geometry.Rect(0.4, 0.6, 2.5, 3.2)
+	golden := []struct {
+		mode geometry.RoundMode
+		want image.Rectangle
+	}{
+		{mode: geometry.RoundNearest, want: image.Rect(0, 1, 3, 3)},
+		{mode: geometry.RoundDown, want: image.Rect(0, 0, 2, 3)},
+		{mode: geometry.RoundUp, want: image.Rect(1, 1, 3, 4)},
+		{mode: geometry.RoundOut, want: image.Rect(0, 0, 3, 4)},
+		{mode: geometry.RoundIn, want: image.Rect(1, 1, 2, 3)},
+	}
+	for _, g := range golden {
+		got := r.Image(g.mode)
+		if got != g.want {
+			t.Errorf("rounding mode %d mismatch; expected %v, got %v", g.mode, g.want, got)
+		}
+	}
+	// Rectangles containing no whole pixel are rounded inwards to the zero
+	// rectangle.
+	for _, r := range []geometry.Rectangle{geometry.Rect(0.2, 0, 0.8, 1), geometry.Rect(0, 1.1, 2, 1.9)} {
+		if got := r.Image(geometry.RoundIn); got != (image.Rectangle{}) {
+			t.Errorf("%v: inward rounding mismatch; expected %v, got %v", r, image.Rectangle{}, got)
+		}
+	}
+}
+
+func TestPointMod(t *testing.T) {
+	r := geometry.Rect(1, 1, 3, 4)
+	golden := []struct {
+		p    geometry.Point
+		want geometry.Point
+	}{
+		{p: geometry.Pt(0, 0), want: geometry.Pt(2, 3)},
+		{p: geometry.Pt(3.5, 4), want: geometry.Pt(1.5, 1)},
+		{p: geometry.Pt(2, 2), want: geometry.Pt(2, 2)},
+	}
+	for _, g := range golden {
+		got := g.p.Mod(r)
+		if got != g.want {
+			t.Errorf("%v.Mod(%v) mismatch; expected %v, got %v", g.p, r, g.want, got)
+		}
+	}
+}
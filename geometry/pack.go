@@ -0,0 +1,213 @@
+package geometry
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// PackOptions specifies the bins and constraints of rectangle packing.
+type PackOptions struct {
+	// Width and height of each bin. If Grow is set, the initial bin size.
+	Width, Height float64
+	// Grow each bin by doubling its width or height (whichever is smaller)
+	// until all rectangles fit or the bin reaches MaxWidth and MaxHeight.
+	Grow bool
+	// Maximum width and height of growing bins.
+	MaxWidth, MaxHeight float64
+	// Allow rectangles to be rotated by 90 degrees.
+	AllowRotate bool
+	// Spacing between packed rectangles.
+	Padding float64
+}
+
+// A Placement specifies the location of a packed rectangle.
+type Placement struct {
+	// Index of the rectangle size in the input slice.
+	Index int
+	// Index of the bin containing the rectangle.
+	Bin int
+	// Location of the rectangle within its bin. The size of Rect is swapped if
+	// Rotated is set.
+	Rect Rectangle
+	// Rectangle rotated by 90 degrees.
+	Rotated bool
+}
+
+// Pack packs rectangles of the given sizes (width and height) into one or more
+// bins using the MaxRects algorithm with the best short side fit heuristic. It
+// returns the placement of each rectangle, in input order, and the size of each
+// bin used.
+func Pack(sizes []Point, opts PackOptions) ([]Placement, []Point, error) {
+	binSize := Pt(opts.Width, opts.Height)
+	maxSize := binSize
+	if opts.Grow {
+		maxSize = Pt(math.Max(opts.MaxWidth, opts.Width), math.Max(opts.MaxHeight, opts.Height))
+	}
+	if binSize.X <= 0 || binSize.Y <= 0 {
+		return nil, nil, errors.Errorf("invalid bin size %v", binSize)
+	}
+	// Validate that each rectangle fits into an empty bin.
+	for i, size := range sizes {
+		if size.X < 0 || size.Y < 0 {
+			return nil, nil, errors.Errorf("invalid size %v of rectangle %d", size, i)
+		}
+		fits := size.X <= maxSize.X && size.Y <= maxSize.Y
+		if opts.AllowRotate {
+			fits = fits || size.Y <= maxSize.X && size.X <= maxSize.Y
+		}
+		if !fits {
+			return nil, nil, errors.Errorf("rectangle %d of size %v does not fit into bin of size %v", i, size, maxSize)
+		}
+	}
+	// Pack large rectangles first.
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		a, b := sizes[i], sizes[j]
+		if c := cmp.Compare(math.Max(b.X, b.Y), math.Max(a.X, a.Y)); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.X*b.Y, a.X*a.Y)
+	})
+	placements := make([]Placement, len(sizes))
+	var bins []Point
+	for len(order) > 0 {
+		size := binSize
+		var placed, rest []int
+		for {
+			placed, rest = packBin(sizes, order, size, opts, placements, len(bins))
+			if len(rest) == 0 || !opts.Grow || (size.X >= maxSize.X && size.Y >= maxSize.Y) {
+				break
+			}
+			size = growBin(size, maxSize)
+		}
+		if len(placed) == 0 {
+			// Unreachable as each rectangle fits into an empty bin.
+			return nil, nil, errors.Errorf("unable to pack rectangle %d into empty bin", rest[0])
+		}
+		bins = append(bins, size)
+		order = rest
+	}
+	return placements, bins, nil
+}
+
+// growBin returns the bin size doubled in its smaller dimension, clamped to
+// maxSize.
+func growBin(size, maxSize Point) Point {
+	if (size.X <= size.Y && size.X < maxSize.X) || size.Y >= maxSize.Y {
+		size.X = math.Min(2*size.X, maxSize.X)
+	} else {
+		size.Y = math.Min(2*size.Y, maxSize.Y)
+	}
+	return size
+}
+
+// packBin packs as many of the rectangles (specified by index in order) into a
+// bin of the given size as possible, recording their placement. It returns the
+// indices of the placed and remaining rectangles.
+func packBin(sizes []Point, order []int, size Point, opts PackOptions, placements []Placement, bin int) (placed, rest []int) {
+	// Padding is added to the right and bottom of each rectangle, and the bin is
+	// extended accordingly so that no padding is required at its edges.
+	pad := opts.Padding
+	b := newMaxRectsBin(size.X+pad, size.Y+pad)
+	for _, i := range order {
+		w, h := sizes[i].X+pad, sizes[i].Y+pad
+		pos, rotated, ok := b.find(w, h, opts.AllowRotate)
+		if !ok {
+			rest = append(rest, i)
+			continue
+		}
+		if rotated {
+			w, h = h, w
+		}
+		b.place(Rect(pos.X, pos.Y, pos.X+w, pos.Y+h))
+		placements[i] = Placement{
+			Index:   i,
+			Bin:     bin,
+			Rect:    Rect(pos.X, pos.Y, pos.X+w-pad, pos.Y+h-pad),
+			Rotated: rotated,
+		}
+		placed = append(placed, i)
+	}
+	return placed, rest
+}
+
+// maxRectsBin tracks the maximal free rectangles of a bin.
+type maxRectsBin struct {
+	// Maximal free rectangles of the bin.
+	free []Rectangle
+}
+
+// newMaxRectsBin returns a new empty bin of the given size.
+func newMaxRectsBin(w, h float64) *maxRectsBin {
+	return &maxRectsBin{free: []Rectangle{Rect(0, 0, w, h)}}
+}
+
+// find locates the position for a rectangle of size w x h using the best short
+// side fit heuristic. The boolean results report whether the rectangle was
+// rotated, and whether a position was found.
+func (b *maxRectsBin) find(w, h float64, allowRotate bool) (pos Point, rotated, ok bool) {
+	bestShort, bestLong := math.Inf(1), math.Inf(1)
+	try := func(w, h float64, rot bool) {
+		for _, f := range b.free {
+			if w > f.Dx() || h > f.Dy() {
+				continue
+			}
+			dw, dh := f.Dx()-w, f.Dy()-h
+			short, long := math.Min(dw, dh), math.Max(dw, dh)
+			if short < bestShort || (short == bestShort && long < bestLong) {
+				bestShort, bestLong = short, long
+				pos, rotated, ok = f.Min, rot, true
+			}
+		}
+	}
+	try(w, h, false)
+	if allowRotate && w != h {
+		try(h, w, true)
+	}
+	return pos, rotated, ok
+}
+
+// place marks the rectangle r as used, splitting the free rectangles it
+// overlaps.
+func (b *maxRectsBin) place(r Rectangle) {
+	var free []Rectangle
+	for _, f := range b.free {
+		if !f.Overlaps(r) {
+			free = append(free, f)
+			continue
+		}
+		// Split f into up to four maximal rectangles around r.
+		if r.Min.X > f.Min.X {
+			free = append(free, Rect(f.Min.X, f.Min.Y, r.Min.X, f.Max.Y))
+		}
+		if r.Max.X < f.Max.X {
+			free = append(free, Rect(r.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if r.Min.Y > f.Min.Y {
+			free = append(free, Rect(f.Min.X, f.Min.Y, f.Max.X, r.Min.Y))
+		}
+		if r.Max.Y < f.Max.Y {
+			free = append(free, Rect(f.Min.X, r.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+	// Prune free rectangles contained within other free rectangles.
+	b.free = b.free[:0]
+	for i, f := range free {
+		redundant := false
+		for j, g := range free {
+			if i != j && f.In(g) && (f != g || i > j) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			b.free = append(b.free, f)
+		}
+	}
+}
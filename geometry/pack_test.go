@@ -0,0 +1,55 @@
+package geometry_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestPack(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var sizes []geometry.Point
+	for i := 0; i < 200; i++ {
+		sizes = append(sizes, geometry.Pt(float64(1+rnd.Intn(64)), float64(1+rnd.Intn(64))))
+	}
+	golden := []geometry.PackOptions{
+		{Width: 256, Height: 256},
+		{Width: 256, Height: 256, AllowRotate: true, Padding: 2},
+		{Width: 64, Height: 64, Grow: true, MaxWidth: 1024, MaxHeight: 1024},
+	}
+	for _, opts := range golden {
+		placements, bins, err := geometry.Pack(sizes, opts)
+		if err != nil {
+			t.Errorf("unable to pack rectangles with options %+v; %v", opts, err)
+			continue
+		}
+		for i, p := range placements {
+			if p.Index != i {
+				t.Errorf("placement index mismatch; expected %d, got %d", i, p.Index)
+			}
+			want := sizes[i]
+			if p.Rotated {
+				want = geometry.Pt(want.Y, want.X)
+			}
+			if p.Rect.Size() != want {
+				t.Errorf("placement size mismatch; expected %v, got %v", want, p.Rect.Size())
+			}
+			bin := geometry.Rectangle{Max: bins[p.Bin]}
+			if !p.Rect.In(bin) {
+				t.Errorf("placement %v outside of bin %v", p.Rect, bin)
+			}
+			for _, q := range placements[i+1:] {
+				if p.Bin == q.Bin && p.Rect.Inset(-opts.Padding/2).Overlaps(q.Rect.Inset(-opts.Padding/2)) {
+					t.Errorf("placements %v and %v overlap", p.Rect, q.Rect)
+				}
+			}
+		}
+		if opts.Grow && len(bins) != 1 {
+			t.Errorf("expected a single growing bin, got %d bins", len(bins))
+		}
+	}
+	if _, _, err := geometry.Pack([]geometry.Point{geometry.Pt(10, 300)}, geometry.PackOptions{Width: 256, Height: 256}); err == nil {
+		t.Errorf("expected error for rectangle larger than bin")
+	}
+}
@@ -0,0 +1,220 @@
+package geometry
+
+import (
+	"strings"
+
+	"golang.org/x/image/vector"
+)
+
+// PathOp specifies the operation of a path segment.
+type PathOp uint8
+
+// Path operations.
+const (
+	// MoveTo starts a new subpath at Points[0].
+	MoveTo PathOp = iota
+	// LineTo adds a line to Points[0].
+	LineTo
+	// QuadTo adds a quadratic Bézier curve with control point Points[0] to
+	// Points[1].
+	QuadTo
+	// CubeTo adds a cubic Bézier curve with control points Points[0] and
+	// Points[1] to Points[2].
+	CubeTo
+	// Close closes the current subpath.
+	Close
+)
+
+// A PathSegment is a segment of a path.
+type PathSegment struct {
+	// Path operation.
+	Op PathOp
+	// Points of the segment; the number of points used depends on Op.
+	Points [3]Point
+}
+
+// A Path is a sequence of subpaths consisting of lines and Bézier curves. The
+// zero value is an empty path ready to use.
+type Path struct {
+	// Segments of the path.
+	Segments []PathSegment
+}
+
+// MoveTo starts a new subpath at p.
+func (path *Path) MoveTo(p Point) {
+	path.Segments = append(path.Segments, PathSegment{Op: MoveTo, Points: [3]Point{p}})
+}
+
+// LineTo adds a line from the current point to p.
+func (path *Path) LineTo(p Point) {
+	path.Segments = append(path.Segments, PathSegment{Op: LineTo, Points: [3]Point{p}})
+}
+
+// QuadTo adds a quadratic Bézier curve from the current point to p with control
+// point c.
+func (path *Path) QuadTo(c, p Point) {
+	path.Segments = append(path.Segments, PathSegment{Op: QuadTo, Points: [3]Point{c, p}})
+}
+
+// CubeTo adds a cubic Bézier curve from the current point to p with control
+// points c1 and c2.
+func (path *Path) CubeTo(c1, c2, p Point) {
+	path.Segments = append(path.Segments, PathSegment{Op: CubeTo, Points: [3]Point{c1, c2, p}})
+}
+
+// Close closes the current subpath with a line to its start point.
+func (path *Path) Close() {
+	path.Segments = append(path.Segments, PathSegment{Op: Close})
+}
+
+// Transform returns the path transformed by m.
+func (path *Path) Transform(m Affine) *Path {
+	t := &Path{Segments: make([]PathSegment, len(path.Segments))}
+	for i, seg := range path.Segments {
+		for j, p := range seg.Points[:seg.Op.npoints()] {
+			seg.Points[j] = m.Apply(p)
+		}
+		t.Segments[i] = seg
+	}
+	return t
+}
+
+// Bounds returns the tight bounding box of the path.
+func (path *Path) Bounds() Rectangle {
+	var ps []Point
+	var cur, start Point
+	for _, seg := range path.Segments {
+		switch seg.Op {
+		case MoveTo:
+			start = seg.Points[0]
+			ps = append(ps, start)
+		case LineTo:
+			ps = append(ps, seg.Points[0])
+		case QuadTo:
+			b := QuadBezier{cur, seg.Points[0], seg.Points[1]}.Bounds()
+			ps = append(ps, b.Min, b.Max)
+		case CubeTo:
+			b := CubicBezier{cur, seg.Points[0], seg.Points[1], seg.Points[2]}.Bounds()
+			ps = append(ps, b.Min, b.Max)
+		}
+		cur = seg.end(start)
+	}
+	return boundingBox(ps)
+}
+
+// Flatten approximates each subpath by a polyline deviating at most tolerance
+// from the path. Closed subpaths end with their start point.
+func (path *Path) Flatten(tolerance float64) []Polyline {
+	var pls []Polyline
+	var pl Polyline
+	var cur, start Point
+	flush := func() {
+		if len(pl) > 1 {
+			pls = append(pls, pl)
+		}
+		pl = nil
+	}
+	for _, seg := range path.Segments {
+		switch seg.Op {
+		case MoveTo:
+			flush()
+			start = seg.Points[0]
+			pl = Polyline{start}
+		case LineTo:
+			if pl == nil {
+				pl = Polyline{cur}
+			}
+			pl = append(pl, seg.Points[0])
+		case QuadTo:
+			if pl == nil {
+				pl = Polyline{cur}
+			}
+			pl = QuadBezier{cur, seg.Points[0], seg.Points[1]}.flatten(pl, tolerance, 0)
+		case CubeTo:
+			if pl == nil {
+				pl = Polyline{cur}
+			}
+			pl = CubicBezier{cur, seg.Points[0], seg.Points[1], seg.Points[2]}.flatten(pl, tolerance, 0)
+		case Close:
+			if pl != nil {
+				pl = append(pl, start)
+			}
+			flush()
+		}
+		cur = seg.end(start)
+	}
+	flush()
+	return pls
+}
+
+// Rasterize adds the path to the given vector rasterizer, which may be used to
+// draw the filled path onto an image.
+func (path *Path) Rasterize(z *vector.Rasterizer) {
+	for _, seg := range path.Segments {
+		p := seg.Points
+		switch seg.Op {
+		case MoveTo:
+			z.MoveTo(float32(p[0].X), float32(p[0].Y))
+		case LineTo:
+			z.LineTo(float32(p[0].X), float32(p[0].Y))
+		case QuadTo:
+			z.QuadTo(float32(p[0].X), float32(p[0].Y), float32(p[1].X), float32(p[1].Y))
+		case CubeTo:
+			z.CubeTo(float32(p[0].X), float32(p[0].Y), float32(p[1].X), float32(p[1].Y), float32(p[2].X), float32(p[2].Y))
+		case Close:
+			z.ClosePath()
+		}
+	}
+}
+
+// SVG returns the path data of the path in SVG syntax (e.g. "M0 0L10 0Z"),
+// suitable for the d attribute of an SVG path element.
+func (path *Path) SVG() string {
+	sb := &strings.Builder{}
+	for _, seg := range path.Segments {
+		switch seg.Op {
+		case MoveTo:
+			sb.WriteString("M")
+		case LineTo:
+			sb.WriteString("L")
+		case QuadTo:
+			sb.WriteString("Q")
+		case CubeTo:
+			sb.WriteString("C")
+		case Close:
+			sb.WriteString("Z")
+		}
+		for i, p := range seg.Points[:seg.Op.npoints()] {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(formatFloat(p.X))
+			sb.WriteString(" ")
+			sb.WriteString(formatFloat(p.Y))
+		}
+	}
+	return sb.String()
+}
+
+// end returns the current point after the path segment, where start is the
+// start point of the current subpath.
+func (seg PathSegment) end(start Point) Point {
+	if seg.Op == Close {
+		return start
+	}
+	return seg.Points[seg.Op.npoints()-1]
+}
+
+// npoints returns the number of points used by the path operation.
+func (op PathOp) npoints() int {
+	switch op {
+	case MoveTo, LineTo:
+		return 1
+	case QuadTo:
+		return 2
+	case CubeTo:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,323 @@
+package geometry
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// A Segment is a line segment between the points A and B.
+type Segment struct {
+	A, B Point
+}
+
+// Seg is shorthand for Segment{A, B}.
+func Seg(a, b Point) Segment {
+	return Segment{a, b}
+}
+
+// Len returns the length of s.
+func (s Segment) Len() float64 {
+	return s.A.Distance(s.B)
+}
+
+// Bounds returns the bounding box of s.
+func (s Segment) Bounds() Rectangle {
+	return boundingBox([]Point{s.A, s.B})
+}
+
+// ClosestPoint returns the point on s closest to p.
+func (s Segment) ClosestPoint(p Point) Point {
+	d := s.B.Sub(s.A)
+	l2 := d.Dot(d)
+	if l2 == 0 {
+		return s.A
+	}
+	t := p.Sub(s.A).Dot(d) / l2
+	t = math.Max(0, math.Min(1, t))
+	return s.A.Lerp(s.B, t)
+}
+
+// Distance returns the distance from p to the closest point on s.
+func (s Segment) Distance(p Point) float64 {
+	return p.Distance(s.ClosestPoint(p))
+}
+
+// Intersect returns the intersection point of s and t. The boolean result is
+// false if the segments do not intersect. For overlapping collinear segments,
+// the intersection point closest to s.A is returned.
+func (s Segment) Intersect(t Segment) (Point, bool) {
+	r := s.B.Sub(s.A)
+	q := t.B.Sub(t.A)
+	denom := r.Cross(q)
+	w := t.A.Sub(s.A)
+	if denom == 0 {
+		if w.Cross(r) != 0 {
+			// Parallel and non-collinear.
+			return ZP, false
+		}
+		// Collinear; project t onto s.
+		l2 := r.Dot(r)
+		if l2 == 0 {
+			// s is a point.
+			if t.Distance(s.A) == 0 {
+				return s.A, true
+			}
+			return ZP, false
+		}
+		t0 := w.Dot(r) / l2
+		t1 := t.B.Sub(s.A).Dot(r) / l2
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t1 < 0 || t0 > 1 {
+			return ZP, false
+		}
+		return s.A.Lerp(s.B, math.Max(0, t0)), true
+	}
+	u := w.Cross(q) / denom
+	v := w.Cross(r) / denom
+	if u < 0 || u > 1 || v < 0 || v > 1 {
+		return ZP, false
+	}
+	return s.A.Lerp(s.B, u), true
+}
+
+// A Polyline is a connected sequence of line segments through its points.
+type Polyline []Point
+
+// Len returns the total length of the polyline.
+func (pl Polyline) Len() float64 {
+	var l float64
+	for i := 1; i < len(pl); i++ {
+		l += pl[i-1].Distance(pl[i])
+	}
+	return l
+}
+
+// Bounds returns the bounding box of the polyline.
+func (pl Polyline) Bounds() Rectangle {
+	return boundingBox(pl)
+}
+
+// Segments returns the line segments of the polyline.
+func (pl Polyline) Segments() []Segment {
+	if len(pl) < 2 {
+		return nil
+	}
+	segs := make([]Segment, 0, len(pl)-1)
+	for i := 1; i < len(pl); i++ {
+		segs = append(segs, Segment{pl[i-1], pl[i]})
+	}
+	return segs
+}
+
+// Transform returns the polyline with each point transformed by m.
+func (pl Polyline) Transform(m Affine) Polyline {
+	return transformPoints(pl, m)
+}
+
+// Simplify returns a simplified polyline using the Ramer–Douglas–Peucker
+// algorithm. Points deviating less than epsilon from the simplified polyline
+// are removed. The end points are always kept.
+func (pl Polyline) Simplify(epsilon float64) Polyline {
+	if len(pl) < 3 {
+		return slices.Clone(pl)
+	}
+	keep := make([]bool, len(pl))
+	keep[0] = true
+	keep[len(pl)-1] = true
+	rdp(pl, 0, len(pl)-1, epsilon, keep)
+	var simplified Polyline
+	for i, p := range pl {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// rdp marks the points between pl[first] and pl[last] to keep based on the
+// Ramer–Douglas–Peucker algorithm.
+func rdp(pl Polyline, first, last int, epsilon float64, keep []bool) {
+	// Use an explicit stack to avoid deep recursion on large inputs.
+	type span struct{ first, last int }
+	stack := []span{{first, last}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		seg := Segment{pl[s.first], pl[s.last]}
+		maxDist, index := -1.0, -1
+		for i := s.first + 1; i < s.last; i++ {
+			if d := seg.Distance(pl[i]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index == -1 || maxDist <= epsilon {
+			continue
+		}
+		keep[index] = true
+		stack = append(stack, span{s.first, index}, span{index, s.last})
+	}
+}
+
+// A Polygon is a closed shape bounded by line segments through its points. The
+// last point is implicitly connected to the first point.
+type Polygon []Point
+
+// SignedArea returns the signed area of the polygon. As the y-axis increases
+// downwards, the area is positive for clockwise polygons on screen.
+func (pg Polygon) SignedArea() float64 {
+	var a float64
+	for i := range pg {
+		j := (i + 1) % len(pg)
+		a += pg[i].Cross(pg[j])
+	}
+	return a / 2
+}
+
+// Area returns the area of the polygon.
+func (pg Polygon) Area() float64 {
+	return math.Abs(pg.SignedArea())
+}
+
+// Perimeter returns the perimeter of the polygon.
+func (pg Polygon) Perimeter() float64 {
+	if len(pg) < 2 {
+		return 0
+	}
+	return Polyline(pg).Len() + pg[len(pg)-1].Distance(pg[0])
+}
+
+// Centroid returns the centroid of the polygon. For degenerate polygons with
+// zero area, the average of the points is returned.
+func (pg Polygon) Centroid() Point {
+	if len(pg) == 0 {
+		return ZP
+	}
+	var c Point
+	var a float64
+	for i := range pg {
+		j := (i + 1) % len(pg)
+		cross := pg[i].Cross(pg[j])
+		a += cross
+		c.X += (pg[i].X + pg[j].X) * cross
+		c.Y += (pg[i].Y + pg[j].Y) * cross
+	}
+	if a == 0 {
+		var sum Point
+		for _, p := range pg {
+			sum = sum.Add(p)
+		}
+		return sum.Div(float64(len(pg)))
+	}
+	return c.Div(3 * a)
+}
+
+// Bounds returns the bounding box of the polygon.
+func (pg Polygon) Bounds() Rectangle {
+	return boundingBox(pg)
+}
+
+// Edges returns the edges of the polygon, including the closing edge from the
+// last point to the first.
+func (pg Polygon) Edges() []Segment {
+	if len(pg) < 2 {
+		return nil
+	}
+	edges := make([]Segment, 0, len(pg))
+	for i := range pg {
+		j := (i + 1) % len(pg)
+		edges = append(edges, Segment{pg[i], pg[j]})
+	}
+	return edges
+}
+
+// Contains reports whether p is inside the polygon, using the even-odd rule.
+// Points on the boundary may be reported as either inside or outside.
+func (pg Polygon) Contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(pg)-1; i < len(pg); j, i = i, i+1 {
+		a, b := pg[i], pg[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) {
+			x := a.X + (p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+			if p.X < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Transform returns the polygon with each point transformed by m.
+func (pg Polygon) Transform(m Affine) Polygon {
+	return transformPoints(pg, m)
+}
+
+// Simplify returns a simplified polygon using the Ramer–Douglas–Peucker
+// algorithm. Points deviating less than epsilon from the simplified polygon are
+// removed.
+func (pg Polygon) Simplify(epsilon float64) Polygon {
+	if len(pg) < 4 {
+		return slices.Clone(pg)
+	}
+	// Split the ring at the point furthest from the first point, and simplify
+	// both halves as open polylines.
+	far, maxDist := 0, -1.0
+	for i, p := range pg {
+		if d := pg[0].Distance(p); d > maxDist {
+			far, maxDist = i, d
+		}
+	}
+	first := Polyline(pg[:far+1]).Simplify(epsilon)
+	second := append(Polyline(slices.Clone(pg[far:])), pg[0]).Simplify(epsilon)
+	simplified := Polygon(first)
+	return append(simplified, second[1:len(second)-1]...)
+}
+
+// ConvexHull returns the convex hull of the given points in clockwise order on
+// screen (i.e. positive signed area), using Andrew's monotone chain algorithm.
+// Collinear points on the hull boundary are omitted.
+func ConvexHull(ps []Point) Polygon {
+	sorted := slices.Clone(ps)
+	slices.SortFunc(sorted, func(a, b Point) int {
+		if c := cmp.Compare(a.X, b.X); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+	sorted = slices.Compact(sorted)
+	if len(sorted) < 3 {
+		return Polygon(sorted)
+	}
+	hull := make(Polygon, 0, 2*len(sorted))
+	// Lower hull.
+	for _, p := range sorted {
+		for len(hull) >= 2 && hull[len(hull)-1].Sub(hull[len(hull)-2]).Cross(p.Sub(hull[len(hull)-2])) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	// Upper hull.
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && hull[len(hull)-1].Sub(hull[len(hull)-2]).Cross(p.Sub(hull[len(hull)-2])) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// transformPoints returns the points of ps transformed by m.
+func transformPoints(ps []Point, m Affine) []Point {
+	if ps == nil {
+		return nil
+	}
+	ts := make([]Point, len(ps))
+	for i, p := range ps {
+		ts[i] = m.Apply(p)
+	}
+	return ts
+}
@@ -0,0 +1,121 @@
+package geometry_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestPolygon(t *testing.T) {
+	// L-shaped polygon.
+	pg := geometry.Polygon{
+		geometry.Pt(0, 0),
+		geometry.Pt(4, 0),
+		geometry.Pt(4, 2),
+		geometry.Pt(2, 2),
+		geometry.Pt(2, 4),
+		geometry.Pt(0, 4),
+	}
+	if got, want := pg.Area(), 12.0; got != want {
+		t.Errorf("area mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := pg.Perimeter(), 16.0; got != want {
+		t.Errorf("perimeter mismatch; expected %v, got %v", want, got)
+	}
+	want := geometry.Pt(5.0/3, 5.0/3)
+	if got := pg.Centroid(); got.Distance(want) > 1e-9 {
+		t.Errorf("centroid mismatch; expected %v, got %v", want, got)
+	}
+	golden := []struct {
+		p    geometry.Point
+		want bool
+	}{
+		{p: geometry.Pt(1, 1), want: true},
+		{p: geometry.Pt(3, 1), want: true},
+		{p: geometry.Pt(3, 3), want: false},
+		{p: geometry.Pt(-1, 1), want: false},
+	}
+	for _, g := range golden {
+		if got := pg.Contains(g.p); got != g.want {
+			t.Errorf("Contains(%v) mismatch; expected %v, got %v", g.p, g.want, got)
+		}
+	}
+}
+
+func TestSegmentIntersect(t *testing.T) {
+	golden := []struct {
+		s, t geometry.Segment
+		want geometry.Point
+		ok   bool
+	}{
+		{
+			s:    geometry.Seg(geometry.Pt(0, 0), geometry.Pt(2, 2)),
+			t:    geometry.Seg(geometry.Pt(0, 2), geometry.Pt(2, 0)),
+			want: geometry.Pt(1, 1),
+			ok:   true,
+		},
+		{
+			s:  geometry.Seg(geometry.Pt(0, 0), geometry.Pt(1, 0)),
+			t:  geometry.Seg(geometry.Pt(0, 1), geometry.Pt(1, 1)),
+			ok: false,
+		},
+		{
+			s:    geometry.Seg(geometry.Pt(0, 0), geometry.Pt(4, 0)),
+			t:    geometry.Seg(geometry.Pt(2, 0), geometry.Pt(6, 0)),
+			want: geometry.Pt(2, 0),
+			ok:   true,
+		},
+	}
+	for _, g := range golden {
+		got, ok := g.s.Intersect(g.t)
+		if ok != g.ok || got != g.want {
+			t.Errorf("%v.Intersect(%v) mismatch; expected %v (%v), got %v (%v)", g.s, g.t, g.want, g.ok, got, ok)
+		}
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	ps := []geometry.Point{
+		geometry.Pt(0, 0),
+		geometry.Pt(2, 0),
+		geometry.Pt(1, 1),
+		geometry.Pt(2, 2),
+		geometry.Pt(0, 2),
+		geometry.Pt(1, 0),
+	}
+	got := geometry.ConvexHull(ps)
+	want := geometry.Polygon{
+		geometry.Pt(0, 0),
+		geometry.Pt(2, 0),
+		geometry.Pt(2, 2),
+		geometry.Pt(0, 2),
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("convex hull mismatch; expected %v, got %v", want, got)
+	}
+	if got.SignedArea() <= 0 {
+		t.Errorf("expected positive signed area, got %v", got.SignedArea())
+	}
+}
+
+func TestPolylineSimplify(t *testing.T) {
+	pl := geometry.Polyline{
+		geometry.Pt(0, 0),
+		geometry.Pt(1, 0.1),
+		geometry.Pt(2, -0.1),
+		geometry.Pt(3, 5),
+		geometry.Pt(4, 6),
+		geometry.Pt(5, 7),
+	}
+	got := pl.Simplify(0.5)
+	want := geometry.Polyline{
+		geometry.Pt(0, 0),
+		geometry.Pt(2, -0.1),
+		geometry.Pt(3, 5),
+		geometry.Pt(5, 7),
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("simplified polyline mismatch; expected %v, got %v", want, got)
+	}
+}
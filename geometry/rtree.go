@@ -0,0 +1,481 @@
+package geometry
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"math"
+	"slices"
+)
+
+// Default node capacity of R-trees.
+const (
+	rtreeMaxEntries = 16
+	rtreeMinEntries = rtreeMaxEntries * 2 / 5
+)
+
+// An RTree is a spatial index of values keyed by their bounding rectangles.
+//
+// Bounds are treated as closed for the purpose of queries, so that rectangles
+// of zero width or height (e.g. points and lines) may be indexed, and bounds
+// which merely touch a query rectangle along an edge are reported as
+// intersecting.
+//
+// The zero value is an empty R-tree ready to use.
+type RTree[T any] struct {
+	// Root node; nil if empty.
+	root *rtreeNode[T]
+	// Number of items in the tree.
+	size int
+}
+
+// An RTreeItem is a value stored in an R-tree with its bounding rectangle.
+type RTreeItem[T any] struct {
+	// Bounding rectangle of the value.
+	Bounds Rectangle
+	// Stored value.
+	Value T
+}
+
+// rtreeNode is a node of an R-tree.
+type rtreeNode[T any] struct {
+	// Leaf nodes store items, inner nodes store child nodes.
+	leaf bool
+	// Entries of the node.
+	entries []rtreeEntry[T]
+}
+
+// rtreeEntry is an entry of an R-tree node.
+type rtreeEntry[T any] struct {
+	// Bounding rectangle of the entry.
+	bounds Rectangle
+	// Child node; nil for leaf entries.
+	child *rtreeNode[T]
+	// Value of leaf entries.
+	value T
+}
+
+// NewRTree returns a new empty R-tree.
+func NewRTree[T any]() *RTree[T] {
+	return &RTree[T]{}
+}
+
+// BulkLoad returns a new R-tree containing the given items, packed using the
+// Sort-Tile-Recursive algorithm. Bulk loading is considerably faster than
+// repeated insertion and produces a tree with better query performance.
+func BulkLoad[T any](items []RTreeItem[T]) *RTree[T] {
+	t := &RTree[T]{size: len(items)}
+	if len(items) == 0 {
+		return t
+	}
+	entries := make([]rtreeEntry[T], len(items))
+	for i, item := range items {
+		entries[i] = rtreeEntry[T]{bounds: item.Bounds.Canon(), value: item.Value}
+	}
+	leaf := true
+	for {
+		nodes := strPack(entries, leaf)
+		if len(nodes) == 1 {
+			t.root = nodes[0]
+			return t
+		}
+		entries = make([]rtreeEntry[T], len(nodes))
+		for i, n := range nodes {
+			entries[i] = rtreeEntry[T]{bounds: n.bounds(), child: n}
+		}
+		leaf = false
+	}
+}
+
+// strPack packs the given entries into nodes of one tree level using the
+// Sort-Tile-Recursive algorithm.
+func strPack[T any](entries []rtreeEntry[T], leaf bool) []*rtreeNode[T] {
+	n := len(entries)
+	nnodes := (n + rtreeMaxEntries - 1) / rtreeMaxEntries
+	nslabs := int(math.Ceil(math.Sqrt(float64(nnodes))))
+	slabSize := nslabs * rtreeMaxEntries
+	slices.SortFunc(entries, func(a, b rtreeEntry[T]) int {
+		return cmp.Compare(a.bounds.Min.X+a.bounds.Max.X, b.bounds.Min.X+b.bounds.Max.X)
+	})
+	var nodes []*rtreeNode[T]
+	for start := 0; start < n; start += slabSize {
+		slab := entries[start:min(start+slabSize, n)]
+		slices.SortFunc(slab, func(a, b rtreeEntry[T]) int {
+			return cmp.Compare(a.bounds.Min.Y+a.bounds.Max.Y, b.bounds.Min.Y+b.bounds.Max.Y)
+		})
+		for i := 0; i < len(slab); i += rtreeMaxEntries {
+			chunk := slab[i:min(i+rtreeMaxEntries, len(slab))]
+			nodes = append(nodes, &rtreeNode[T]{
+				leaf:    leaf,
+				entries: slices.Clone(chunk),
+			})
+		}
+	}
+	return nodes
+}
+
+// Len returns the number of items in the R-tree.
+func (t *RTree[T]) Len() int {
+	return t.size
+}
+
+// Bounds returns the bounding rectangle of all items in the R-tree, or the zero
+// rectangle if the tree is empty.
+func (t *RTree[T]) Bounds() Rectangle {
+	if t.root == nil {
+		return ZR
+	}
+	return t.root.bounds()
+}
+
+// Insert inserts the value v with bounding rectangle r into the R-tree.
+func (t *RTree[T]) Insert(r Rectangle, v T) {
+	t.insert(rtreeEntry[T]{bounds: r.Canon(), value: v})
+	t.size++
+}
+
+// insert inserts the leaf entry e into the R-tree.
+func (t *RTree[T]) insert(e rtreeEntry[T]) {
+	if t.root == nil {
+		t.root = &rtreeNode[T]{leaf: true}
+	}
+	if split := t.root.insert(e); split != nil {
+		// Grow the tree by one level.
+		t.root = &rtreeNode[T]{
+			entries: []rtreeEntry[T]{
+				{bounds: t.root.bounds(), child: t.root},
+				{bounds: split.bounds(), child: split},
+			},
+		}
+	}
+}
+
+// insert inserts the leaf entry e into the subtree rooted at n. If n overflows,
+// it is split in two and the new sibling node is returned.
+func (n *rtreeNode[T]) insert(e rtreeEntry[T]) *rtreeNode[T] {
+	if !n.leaf {
+		i := n.chooseSubtree(e.bounds)
+		child := &n.entries[i]
+		split := child.child.insert(e)
+		child.bounds = child.child.bounds()
+		if split != nil {
+			n.entries = append(n.entries, rtreeEntry[T]{bounds: split.bounds(), child: split})
+		}
+	} else {
+		n.entries = append(n.entries, e)
+	}
+	if len(n.entries) > rtreeMaxEntries {
+		return n.split()
+	}
+	return nil
+}
+
+// chooseSubtree returns the index of the entry of n requiring the least
+// enlargement to include r, resolving ties by choosing the smallest entry.
+func (n *rtreeNode[T]) chooseSubtree(r Rectangle) int {
+	best := -1
+	var bestEnlargement, bestArea float64
+	for i, e := range n.entries {
+		area := closedArea(e.bounds)
+		enlargement := closedArea(extend(e.bounds, r)) - area
+		if best == -1 || enlargement < bestEnlargement || (enlargement == bestEnlargement && area < bestArea) {
+			best, bestEnlargement, bestArea = i, enlargement, area
+		}
+	}
+	return best
+}
+
+// split splits the entries of n in two using Guttman's quadratic split
+// algorithm, and returns the new sibling node.
+func (n *rtreeNode[T]) split() *rtreeNode[T] {
+	entries := n.entries
+	// Pick the pair of entries wasting the most area as seeds.
+	seed1, seed2 := 0, 1
+	worst := math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := entries[i].bounds, entries[j].bounds
+			d := closedArea(extend(a, b)) - closedArea(a) - closedArea(b)
+			if d > worst {
+				worst, seed1, seed2 = d, i, j
+			}
+		}
+	}
+	group1 := []rtreeEntry[T]{entries[seed1]}
+	group2 := []rtreeEntry[T]{entries[seed2]}
+	bounds1, bounds2 := entries[seed1].bounds, entries[seed2].bounds
+	var rest []rtreeEntry[T]
+	for i, e := range entries {
+		if i != seed1 && i != seed2 {
+			rest = append(rest, e)
+		}
+	}
+	for len(rest) > 0 {
+		// Ensure both groups reach the minimum number of entries.
+		if len(group1)+len(rest) == rtreeMinEntries {
+			group1 = append(group1, rest...)
+			break
+		}
+		if len(group2)+len(rest) == rtreeMinEntries {
+			group2 = append(group2, rest...)
+			break
+		}
+		// Pick the entry with the greatest preference for one group.
+		next, maxDiff := 0, -1.0
+		var d1, d2 float64
+		for i, e := range rest {
+			e1 := closedArea(extend(bounds1, e.bounds)) - closedArea(bounds1)
+			e2 := closedArea(extend(bounds2, e.bounds)) - closedArea(bounds2)
+			if diff := math.Abs(e1 - e2); diff > maxDiff {
+				next, maxDiff, d1, d2 = i, diff, e1, e2
+			}
+		}
+		e := rest[next]
+		rest = slices.Delete(rest, next, next+1)
+		if d1 < d2 || (d1 == d2 && len(group1) <= len(group2)) {
+			group1 = append(group1, e)
+			bounds1 = extend(bounds1, e.bounds)
+		} else {
+			group2 = append(group2, e)
+			bounds2 = extend(bounds2, e.bounds)
+		}
+	}
+	n.entries = group1
+	return &rtreeNode[T]{leaf: n.leaf, entries: group2}
+}
+
+// Delete removes the first item with bounding rectangle r for which match
+// returns true, and reports whether an item was removed.
+func (t *RTree[T]) Delete(r Rectangle, match func(v T) bool) bool {
+	if t.root == nil {
+		return false
+	}
+	r = r.Canon()
+	var orphans []rtreeEntry[T]
+	if !t.root.delete(r, match, &orphans) {
+		return false
+	}
+	t.size--
+	// Shrink the tree while the root has a single child.
+	for !t.root.leaf && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+	}
+	if len(t.root.entries) == 0 {
+		t.root = nil
+	}
+	// Reinsert the items of underfull nodes.
+	for _, e := range orphans {
+		t.insert(e)
+	}
+	return true
+}
+
+// delete removes the first leaf entry with bounding rectangle r for which match
+// returns true from the subtree rooted at n. The leaf entries of underfull nodes
+// removed from the subtree are appended to orphans.
+func (n *rtreeNode[T]) delete(r Rectangle, match func(v T) bool, orphans *[]rtreeEntry[T]) bool {
+	if n.leaf {
+		for i, e := range n.entries {
+			if e.bounds == r && match(e.value) {
+				n.entries = slices.Delete(n.entries, i, i+1)
+				return true
+			}
+		}
+		return false
+	}
+	for i := range n.entries {
+		e := &n.entries[i]
+		if !contains(e.bounds, r) {
+			continue
+		}
+		if !e.child.delete(r, match, orphans) {
+			continue
+		}
+		if len(e.child.entries) < rtreeMinEntries {
+			for item := range e.child.all() {
+				*orphans = append(*orphans, item)
+			}
+			n.entries = slices.Delete(n.entries, i, i+1)
+		} else {
+			e.bounds = e.child.bounds()
+		}
+		return true
+	}
+	return false
+}
+
+// Clear removes all items from the R-tree.
+func (t *RTree[T]) Clear() {
+	t.root = nil
+	t.size = 0
+}
+
+// All returns an iterator over all items in the R-tree.
+func (t *RTree[T]) All() iter.Seq2[Rectangle, T] {
+	return func(yield func(Rectangle, T) bool) {
+		if t.root == nil {
+			return
+		}
+		for e := range t.root.all() {
+			if !yield(e.bounds, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// all returns an iterator over the leaf entries of the subtree rooted at n.
+func (n *rtreeNode[T]) all() iter.Seq[rtreeEntry[T]] {
+	return func(yield func(rtreeEntry[T]) bool) {
+		n.walk(func(e rtreeEntry[T]) bool { return true }, yield)
+	}
+}
+
+// Search returns an iterator over the items whose bounding rectangles intersect
+// r.
+func (t *RTree[T]) Search(r Rectangle) iter.Seq2[Rectangle, T] {
+	r = r.Canon()
+	return t.search(func(bounds Rectangle) bool {
+		return intersects(bounds, r)
+	})
+}
+
+// SearchPoint returns an iterator over the items whose bounding rectangles
+// contain p.
+func (t *RTree[T]) SearchPoint(p Point) iter.Seq2[Rectangle, T] {
+	return t.Search(Rectangle{p, p})
+}
+
+// search returns an iterator over the items whose bounding rectangles satisfy
+// the given predicate. The predicate must also hold for every node enclosing a
+// matching item.
+func (t *RTree[T]) search(pred func(bounds Rectangle) bool) iter.Seq2[Rectangle, T] {
+	return func(yield func(Rectangle, T) bool) {
+		if t.root == nil {
+			return
+		}
+		t.root.walk(func(e rtreeEntry[T]) bool {
+			return pred(e.bounds)
+		}, func(e rtreeEntry[T]) bool {
+			return yield(e.bounds, e.value)
+		})
+	}
+}
+
+// walk calls yield for each leaf entry of the subtree rooted at n, descending
+// only into entries satisfying pred. The boolean result is false if iteration
+// was stopped by yield.
+func (n *rtreeNode[T]) walk(pred func(e rtreeEntry[T]) bool, yield func(rtreeEntry[T]) bool) bool {
+	for _, e := range n.entries {
+		if !pred(e) {
+			continue
+		}
+		if n.leaf {
+			if !yield(e) {
+				return false
+			}
+		} else if !e.child.walk(pred, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Nearest returns an iterator over the items of the R-tree in order of
+// increasing distance from p to their bounding rectangles. Use a break
+// statement to stop after the k nearest neighbours.
+func (t *RTree[T]) Nearest(p Point) iter.Seq2[Rectangle, T] {
+	return func(yield func(Rectangle, T) bool) {
+		if t.root == nil {
+			return
+		}
+		q := &rtreeQueue[T]{}
+		for _, e := range t.root.entries {
+			heap.Push(q, rtreeQueueItem[T]{entry: e, dist: rectDistance(e.bounds, p)})
+		}
+		for q.Len() > 0 {
+			item := heap.Pop(q).(rtreeQueueItem[T])
+			if item.entry.child == nil {
+				if !yield(item.entry.bounds, item.entry.value) {
+					return
+				}
+				continue
+			}
+			for _, e := range item.entry.child.entries {
+				heap.Push(q, rtreeQueueItem[T]{entry: e, dist: rectDistance(e.bounds, p)})
+			}
+		}
+	}
+}
+
+// rtreeQueueItem is an entry of a nearest-neighbour priority queue.
+type rtreeQueueItem[T any] struct {
+	// R-tree node or leaf entry.
+	entry rtreeEntry[T]
+	// Distance from the query point to the entry.
+	dist float64
+}
+
+// rtreeQueue is a nearest-neighbour priority queue, implementing
+// heap.Interface.
+type rtreeQueue[T any] []rtreeQueueItem[T]
+
+func (q rtreeQueue[T]) Len() int           { return len(q) }
+func (q rtreeQueue[T]) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q rtreeQueue[T]) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *rtreeQueue[T]) Push(x any) {
+	*q = append(*q, x.(rtreeQueueItem[T]))
+}
+
+func (q *rtreeQueue[T]) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// bounds returns the bounding rectangle of the entries of n.
+func (n *rtreeNode[T]) bounds() Rectangle {
+	if len(n.entries) == 0 {
+		return ZR
+	}
+	r := n.entries[0].bounds
+	for _, e := range n.entries[1:] {
+		r = extend(r, e.bounds)
+	}
+	return r
+}
+
+// extend returns the smallest rectangle containing both r and s. Unlike
+// Rectangle.Union, rectangles of zero width or height are not ignored.
+func extend(r, s Rectangle) Rectangle {
+	return Rectangle{
+		Min: Point{math.Min(r.Min.X, s.Min.X), math.Min(r.Min.Y, s.Min.Y)},
+		Max: Point{math.Max(r.Max.X, s.Max.X), math.Max(r.Max.Y, s.Max.Y)},
+	}
+}
+
+// intersects reports whether the closed rectangles r and s intersect.
+func intersects(r, s Rectangle) bool {
+	return r.Min.X <= s.Max.X && s.Min.X <= r.Max.X &&
+		r.Min.Y <= s.Max.Y && s.Min.Y <= r.Max.Y
+}
+
+// contains reports whether the closed rectangle r contains s.
+func contains(r, s Rectangle) bool {
+	return r.Min.X <= s.Min.X && s.Max.X <= r.Max.X &&
+		r.Min.Y <= s.Min.Y && s.Max.Y <= r.Max.Y
+}
+
+// closedArea returns the area of the well-formed rectangle r.
+func closedArea(r Rectangle) float64 {
+	return r.Dx() * r.Dy()
+}
+
+// rectDistance returns the distance from p to the closest point of r.
+func rectDistance(r Rectangle, p Point) float64 {
+	dx := math.Max(0, math.Max(r.Min.X-p.X, p.X-r.Max.X))
+	dy := math.Max(0, math.Max(r.Min.Y-p.Y, p.Y-r.Max.Y))
+	return math.Hypot(dx, dy)
+}
@@ -0,0 +1,95 @@
+package geometry_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+func TestRTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var items []geometry.RTreeItem[int]
+	for i := 0; i < 1000; i++ {
+		x, y := rnd.Float64()*1000, rnd.Float64()*1000
+		w, h := rnd.Float64()*20, rnd.Float64()*20
+		items = append(items, geometry.RTreeItem[int]{Bounds: geometry.Rect(x, y, x+w, y+h), Value: i})
+	}
+	inserted := geometry.NewRTree[int]()
+	for _, item := range items {
+		inserted.Insert(item.Bounds, item.Value)
+	}
+	loaded := geometry.BulkLoad(slices.Clone(items))
+	// Delete every third item.
+	for i := 0; i < len(items); i += 3 {
+		item := items[i]
+		match := func(v int) bool { return v == item.Value }
+		if !inserted.Delete(item.Bounds, match) {
+			t.Fatalf("unable to delete item %d from inserted tree", i)
+		}
+		if !loaded.Delete(item.Bounds, match) {
+			t.Fatalf("unable to delete item %d from bulk loaded tree", i)
+		}
+	}
+	var remaining []geometry.RTreeItem[int]
+	for i, item := range items {
+		if i%3 != 0 {
+			remaining = append(remaining, item)
+		}
+	}
+	for name, tree := range map[string]*geometry.RTree[int]{"inserted": inserted, "loaded": loaded} {
+		if tree.Len() != len(remaining) {
+			t.Errorf("%s: length mismatch; expected %d, got %d", name, len(remaining), tree.Len())
+		}
+		for j := 0; j < 50; j++ {
+			x, y := rnd.Float64()*1000, rnd.Float64()*1000
+			query := geometry.Rect(x, y, x+100, y+100)
+			var want, got []int
+			for _, item := range remaining {
+				if item.Bounds.Overlaps(query) {
+					want = append(want, item.Value)
+				}
+			}
+			for _, v := range tree.Search(query) {
+				got = append(got, v)
+			}
+			slices.Sort(got)
+			if !slices.Equal(want, got) {
+				t.Errorf("%s: search %v mismatch; expected %v, got %v", name, query, want, got)
+			}
+		}
+		// Nearest neighbours must be reported in order of increasing distance.
+		p := geometry.Pt(500, 500)
+		prev, n := -1.0, 0
+		for r := range tree.Nearest(p) {
+			dist := distance(r, p)
+			if dist < prev {
+				t.Errorf("%s: nearest neighbour out of order; %v < %v", name, dist, prev)
+			}
+			prev = dist
+			n++
+		}
+		if n != len(remaining) {
+			t.Errorf("%s: nearest neighbour count mismatch; expected %d, got %d", name, len(remaining), n)
+		}
+	}
+}
+
+func TestBulkLoadInverted(t *testing.T) {
+	// Bounds with Min > Max are canonicalised, as by Insert and Delete.
+	inverted := geometry.Rectangle{Min: geometry.Pt(5, 5), Max: geometry.Pt(1, 1)}
+	tree := geometry.BulkLoad([]geometry.RTreeItem[int]{{Bounds: inverted, Value: 1}})
+	if want, got := inverted.Canon(), tree.Bounds(); got != want {
+		t.Errorf("bounds mismatch; expected %v, got %v", want, got)
+	}
+	if !tree.Delete(inverted, func(v int) bool { return v == 1 }) {
+		t.Errorf("unable to delete bulk loaded item with inverted bounds")
+	}
+}
+
+// distance returns the distance from p to the closest point of r.
+func distance(r geometry.Rectangle, p geometry.Point) float64 {
+	q := geometry.Pt(min(max(p.X, r.Min.X), r.Max.X), min(max(p.Y, r.Min.Y), r.Max.Y))
+	return q.Distance(p)
+}
@@ -10,3 +10,5 @@ require (
 	golang.org/x/image v0.41.0
 	golang.org/x/net v0.55.0
 )
+
+require golang.org/x/text v0.37.0 // indirect
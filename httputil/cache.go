@@ -0,0 +1,304 @@
+package httputil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mewkiz/pkg/osutil"
+	"github.com/pkg/errors"
+)
+
+// Error values.
+var (
+	// ErrCacheMiss is returned by a CacheTransport in offline mode for requests
+	// without a cached response.
+	ErrCacheMiss = errors.New("httputil: cache miss")
+)
+
+// CacheHeader is the header set to "1" on responses served from the cache of a
+// CacheTransport.
+const CacheHeader = "X-From-Cache"
+
+// A CacheTransport is an http.RoundTripper which caches responses on disk. To
+// use it with a session, set the Transport of the session's Client.
+//
+// Successful (200) responses to GET requests without a Range header are
+// cached, keyed by URL and the request headers listed in the Vary header of the
+// response. Cached responses are served while fresh according to their
+// Cache-Control max-age, Expires or Last-Modified headers, and revalidated
+// with a conditional request using their ETag or Last-Modified headers once
+// stale. Responses with Cache-Control no-store or Vary * are not cached, and
+// responses with Cache-Control no-cache are revalidated before each use.
+//
+// Responses with a Set-Cookie header and responses to requests with an
+// Authorization header are not cached unless marked Cache-Control public. The
+// Set-Cookie headers of cached responses are not stored.
+type CacheTransport struct {
+	// Directory of cached responses, created if not present.
+	Dir string
+	// Underlying transport used for requests; http.DefaultTransport is used if
+	// nil.
+	Transport http.RoundTripper
+	// Serve all requests from the cache, regardless of freshness, without
+	// network access. ErrCacheMiss is returned for requests without a cached
+	// response.
+	Offline bool
+}
+
+// RoundTrip executes a single HTTP transaction, serving the response from the
+// cache if possible.
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqCC := req.Header.Get("Cache-Control")
+	_, noStore := cacheControlValue(reqCC, "no-store")
+	if req.Method != "GET" || len(req.Header.Get("Range")) > 0 || noStore {
+		if t.Offline {
+			return nil, errors.Wrapf(ErrCacheMiss, "%s %s", req.Method, req.URL)
+		}
+		return t.transport().RoundTrip(req)
+	}
+	entryPath := t.entryPath(req)
+	cached, stored, err := t.load(req, entryPath)
+	if err != nil {
+		return nil, err
+	}
+	if t.Offline {
+		if cached == nil {
+			return nil, errors.Wrapf(ErrCacheMiss, "GET %s", req.URL)
+		}
+		return cached, nil
+	}
+	_, noCache := cacheControlValue(reqCC, "no-cache")
+	if cached != nil && !noCache && isFresh(cached, stored) {
+		return cached, nil
+	}
+	// Revalidate the cached response with a conditional request.
+	if cached != nil {
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if len(etag) == 0 && len(lastModified) == 0 {
+			cached.Body.Close()
+			cached = nil
+		} else {
+			req = req.Clone(req.Context())
+			if len(etag) > 0 {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if len(lastModified) > 0 {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		// Update the cached response with the headers of the 304 response.
+		resp.Body.Close()
+		for key, values := range resp.Header {
+			if key != "Content-Length" && key != "Transfer-Encoding" {
+				cached.Header[key] = values
+			}
+		}
+		body, err := io.ReadAll(cached.Body)
+		cached.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := t.store(req, cached, body); err != nil {
+			return nil, err
+		}
+		cached.Body = io.NopCloser(bytes.NewReader(body))
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+	if !isCacheable(req, resp) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := t.store(req, resp, body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// transport returns the underlying transport of the cache.
+func (t *CacheTransport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// cacheKey returns the cache key of the request, based on its method, URL and
+// the given request headers.
+func cacheKey(req *http.Request, vary []string) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.String()+"\n")
+	for _, key := range vary {
+		io.WriteString(h, key+": "+strings.Join(req.Header.Values(key), ", ")+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// varyPath returns the path of the file listing the Vary headers of the cached
+// responses of the request's method and URL.
+func (t *CacheTransport) varyPath(req *http.Request) string {
+	return filepath.Join(t.Dir, cacheKey(req, nil)+".vary")
+}
+
+// entryPath returns the path of the cached response of the request.
+func (t *CacheTransport) entryPath(req *http.Request) string {
+	var vary []string
+	if buf, err := os.ReadFile(t.varyPath(req)); err == nil {
+		vary = strings.Fields(string(buf))
+	}
+	return filepath.Join(t.Dir, cacheKey(req, vary)+".resp")
+}
+
+// load loads the cached response of the request from entryPath, along with the
+// time it was stored. The returned response is nil if not cached.
+func (t *CacheTransport) load(req *http.Request, entryPath string) (*http.Response, time.Time, error) {
+	buf, err := os.ReadFile(entryPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, time.Time{}, nil
+		}
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+	fi, err := os.Stat(entryPath)
+	if err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf)), req)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrapf(err, "unable to parse cached response %q", entryPath)
+	}
+	resp.Header.Set(CacheHeader, "1")
+	return resp, fi.ModTime(), nil
+}
+
+// store stores the response to the request with the given body in the cache.
+func (t *CacheTransport) store(req *http.Request, resp *http.Response, body []byte) error {
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	var vary []string
+	for _, value := range resp.Header.Values("Vary") {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); len(key) > 0 {
+				vary = append(vary, http.CanonicalHeaderKey(key))
+			}
+		}
+	}
+	slices.Sort(vary)
+	vary = slices.Compact(vary)
+	if err := writeFileAtomic(t.varyPath(req), []byte(strings.Join(vary, "\n"))); err != nil {
+		return err
+	}
+	r := *resp
+	r.Header = resp.Header.Clone()
+	r.Header.Del(CacheHeader)
+	r.Header.Del("Set-Cookie")
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	r.Trailer = nil
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return errors.WithStack(err)
+	}
+	entryPath := filepath.Join(t.Dir, cacheKey(req, vary)+".resp")
+	return writeFileAtomic(entryPath, buf.Bytes())
+}
+
+// isCacheable reports whether the response to the request may be stored in the
+// cache.
+func isCacheable(req *http.Request, resp *http.Response) bool {
+	cc := resp.Header.Get("Cache-Control")
+	_, noStore := cacheControlValue(cc, "no-store")
+	if resp.StatusCode != http.StatusOK || noStore || resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	// Responses which may be specific to a user are only cached if explicitly
+	// marked as shareable.
+	if len(resp.Header.Values("Set-Cookie")) > 0 || len(req.Header.Get("Authorization")) > 0 {
+		_, public := cacheControlValue(cc, "public")
+		return public
+	}
+	return true
+}
+
+// isFresh reports whether the cached response, stored at the given time, is
+// fresh.
+func isFresh(resp *http.Response, stored time.Time) bool {
+	cc := resp.Header.Get("Cache-Control")
+	if _, noCache := cacheControlValue(cc, "no-cache"); noCache {
+		return false
+	}
+	age := time.Since(stored)
+	if secs, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		age += time.Duration(secs) * time.Second
+	}
+	var lifetime time.Duration
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		date = stored
+	}
+	if maxAge, ok := cacheControlValue(cc, "max-age"); ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(secs) * time.Second
+	} else if expires := resp.Header.Get("Expires"); len(expires) > 0 {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return false
+		}
+		lifetime = t.Sub(date)
+	} else if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		// Heuristic freshness of 10% of the time since last modification.
+		lifetime = date.Sub(lastModified) / 10
+	}
+	return age < lifetime
+}
+
+// cacheControlValue returns the value of the given Cache-Control directive.
+func cacheControlValue(cc, directive string) (string, bool) {
+	for _, part := range strings.Split(cc, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if strings.EqualFold(key, directive) {
+			return strings.Trim(value, `"`), true
+		}
+	}
+	return "", false
+}
+
+// writeFileAtomic writes data to the named file, replacing it atomically; see
+// osutil.WriteFileAtomic.
+func writeFileAtomic(path string, data []byte) error {
+	return osutil.WriteFileAtomic(path, 0o666, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return errors.WithStack(err)
+	})
+}
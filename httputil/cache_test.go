@@ -0,0 +1,142 @@
+package httputil_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewkiz/pkg/httputil"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var hits, revalidations int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		switch req.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			fmt.Fprint(w, "fresh")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				revalidations++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fmt.Fprint(w, "etag")
+		case "/lang":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Vary", "Accept-Language")
+			fmt.Fprint(w, req.Header.Get("Accept-Language"))
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			fmt.Fprint(w, "nostore")
+		case "/cookie":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+			fmt.Fprint(w, "cookie")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+			fmt.Fprint(w, "public")
+		case "/auth":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			fmt.Fprint(w, "auth")
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache := &httputil.CacheTransport{Dir: dir}
+	sess := &httputil.Session{Client: &http.Client{Transport: cache}}
+	golden := []struct {
+		path  string
+		lang  string
+		token string
+		want  string
+		// Number of server hits after the request.
+		hits int
+	}{
+		{path: "/fresh", want: "fresh", hits: 1},
+		{path: "/fresh", want: "fresh", hits: 1},
+		{path: "/etag", want: "etag", hits: 2},
+		{path: "/etag", want: "etag", hits: 3},
+		{path: "/lang", lang: "en", want: "en", hits: 4},
+		{path: "/lang", lang: "sv", want: "sv", hits: 5},
+		{path: "/lang", lang: "sv", want: "sv", hits: 5},
+		{path: "/nostore", want: "nostore", hits: 6},
+		{path: "/nostore", want: "nostore", hits: 7},
+		// Responses with Set-Cookie or to requests with Authorization are only
+		// cached if public.
+		{path: "/cookie", want: "cookie", hits: 8},
+		{path: "/cookie", want: "cookie", hits: 9},
+		{path: "/public", want: "public", hits: 10},
+		{path: "/public", want: "public", hits: 10},
+		{path: "/auth", token: "t0k3n", want: "auth", hits: 11},
+		{path: "/auth", token: "t0k3n", want: "auth", hits: 12},
+	}
+	for i, g := range golden {
+		sess.Header = http.Header{"Accept-Language": {g.lang}}
+		sess.BearerToken = g.token
+		got, err := sess.GetString(srv.URL + g.path)
+		if err != nil {
+			t.Errorf("i=%d: unable to get %q; %v", i, g.path, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("i=%d: response mismatch; expected %q, got %q", i, g.want, got)
+		}
+		if hits != g.hits {
+			t.Errorf("i=%d: server hit count mismatch; expected %d, got %d", i, g.hits, hits)
+		}
+	}
+	if revalidations != 1 {
+		t.Errorf("revalidation count mismatch; expected 1, got %d", revalidations)
+	}
+
+	// Set-Cookie headers are not stored.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		buf, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(buf, []byte("s3cr3t")) {
+			t.Errorf("%q: cached response contains cookie", entry.Name())
+		}
+	}
+
+	// Offline mode.
+	cache.Offline = true
+	sess.Header = nil
+	if got, err := sess.GetString(srv.URL + "/etag"); err != nil || got != "etag" {
+		t.Errorf("offline response mismatch; expected %q, got %q (%v)", "etag", got, err)
+	}
+	if _, err := sess.GetString(srv.URL + "/nostore"); !errors.Is(err, httputil.ErrCacheMiss) {
+		t.Errorf("error mismatch; expected %v, got %v", httputil.ErrCacheMiss, err)
+	}
+	req, err := http.NewRequest("POST", srv.URL+"/fresh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.RoundTrip(req)
+	if !errors.Is(err, httputil.ErrCacheMiss) {
+		t.Errorf("error mismatch; expected %v, got %v", httputil.ErrCacheMiss, err)
+	}
+	if want := "POST " + req.URL.String(); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error message mismatch; expected %q in %v", want, err)
+	}
+	if hits != 12 {
+		t.Errorf("server hit count mismatch in offline mode; expected 12, got %d", hits)
+	}
+}
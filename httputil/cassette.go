@@ -0,0 +1,359 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Error values.
+var (
+	// ErrNoInteraction is returned by a Cassette in replay mode for requests
+	// without a matching recorded interaction.
+	ErrNoInteraction = errors.New("httputil: no recorded interaction")
+)
+
+// CassetteMode specifies whether a cassette replays or records interactions.
+type CassetteMode uint8
+
+// Cassette modes.
+const (
+	// Replay recorded interactions only, without network access.
+	ModeReplay CassetteMode = iota
+	// Send all requests and record their interactions, discarding any
+	// previously recorded ones.
+	ModeRecord
+	// Replay recorded interactions, and send and record requests without a
+	// matching recorded interaction.
+	ModeReplayOrRecord
+)
+
+// Redacted is the value replacing redacted header values of recorded
+// interactions.
+const Redacted = "REDACTED"
+
+// DefaultRedact lists the headers redacted by default in recorded interactions.
+var DefaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// A Cassette is an http.RoundTripper which records HTTP interactions to a JSON
+// file and replays them, to test code making HTTP requests without network
+// access. To use it, set the Transport of a session's Client, or of the default
+// httputil client (see SetClient).
+//
+// Recorded interactions are written to the cassette file as soon as they
+// complete. When replaying, each recorded interaction matching a request is
+// used once, in order of recording; once all matching interactions have been
+// used, the last one is reused.
+//
+// The cassette file is loaded on first use, unless the mode is ModeRecord, so
+// a Cassette may also be created as a struct literal; its Path and Mode must
+// not be changed thereafter.
+type Cassette struct {
+	// Path of the cassette file.
+	Path string
+	// Mode of the cassette.
+	Mode CassetteMode
+	// Underlying transport used for requests when recording;
+	// http.DefaultTransport is used if nil.
+	Transport http.RoundTripper
+	// Matchers used to find the recorded interaction of a request; all must
+	// match. MatchMethod and MatchURL are used if empty.
+	Matchers []Matcher
+	// Request and response headers whose values are replaced with Redacted in
+	// recorded interactions. DefaultRedact is used if nil.
+	Redact []string
+
+	// Recorded interactions and whether each has been replayed; loaded from
+	// the cassette file on first use.
+	mu           sync.Mutex
+	loaded       bool
+	interactions []*Interaction
+	used         []bool
+}
+
+// An Interaction is a recorded HTTP request and its response.
+type Interaction struct {
+	// Recorded request.
+	Request RecordedRequest `json:"request"`
+	// Recorded response.
+	Response RecordedResponse `json:"response"`
+}
+
+// A RecordedRequest is a recorded HTTP request.
+type RecordedRequest struct {
+	// HTTP method (e.g. "GET").
+	Method string `json:"method"`
+	// Request URL.
+	URL string `json:"url"`
+	// Request headers.
+	Header http.Header `json:"header,omitempty"`
+	// Request body.
+	Body Body `json:"body,omitempty"`
+}
+
+// A RecordedResponse is a recorded HTTP response.
+type RecordedResponse struct {
+	// Status code (e.g. 200).
+	StatusCode int `json:"status_code"`
+	// Response headers.
+	Header http.Header `json:"header,omitempty"`
+	// Response body.
+	Body Body `json:"body,omitempty"`
+}
+
+// A Body is a recorded request or response body. It is encoded in JSON as a
+// string if valid UTF-8, and as an object with a base64-encoded "base64"
+// field otherwise.
+type Body []byte
+
+// MarshalJSON returns the JSON encoding of the body.
+func (body Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(body) {
+		return json.Marshal(string(body))
+	}
+	return json.Marshal(struct {
+		Base64 []byte `json:"base64"`
+	}{Base64: body})
+}
+
+// UnmarshalJSON decodes the JSON encoding of the body.
+func (body *Body) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return errors.WithStack(err)
+		}
+		*body = Body(s)
+		return nil
+	}
+	var v struct {
+		Base64 []byte `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return errors.WithStack(err)
+	}
+	*body = v.Base64
+	return nil
+}
+
+// A Matcher reports whether the request, with the given body, matches the
+// recorded request.
+type Matcher func(req *http.Request, body []byte, rec *RecordedRequest) bool
+
+// MatchMethod matches requests by HTTP method.
+func MatchMethod(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.Method == rec.Method
+}
+
+// MatchURL matches requests by URL.
+func MatchURL(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.URL.String() == rec.URL
+}
+
+// MatchBody matches requests by body.
+func MatchBody(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return bytes.Equal(body, rec.Body)
+}
+
+// NewCassette returns a new cassette of the given mode, loading the recorded
+// interactions of the cassette file specified by cassettePath unless mode is
+// ModeRecord. The cassette file must exist in ModeReplay.
+func NewCassette(cassettePath string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{Path: cassettePath, Mode: mode}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load loads the recorded interactions of the cassette file unless already
+// loaded, or the mode is ModeRecord. The caller must hold c.mu.
+func (c *Cassette) load() error {
+	if c.loaded {
+		return nil
+	}
+	if c.Mode != ModeRecord {
+		buf, err := os.ReadFile(c.Path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(buf, &c.interactions); err != nil {
+				return errors.Wrapf(err, "unable to parse cassette %q", c.Path)
+			}
+		case !os.IsNotExist(err) || c.Mode == ModeReplay:
+			return errors.WithStack(err)
+		}
+	}
+	c.used = make([]bool, len(c.interactions))
+	c.loaded = true
+	return nil
+}
+
+// Interactions returns the recorded interactions of the cassette, loading the
+// cassette file unless already loaded. It returns nil if the cassette file
+// cannot be loaded.
+func (c *Cassette) Interactions() []*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil
+	}
+	return slices.Clone(c.interactions)
+}
+
+// RoundTrip executes a single HTTP transaction, replaying or recording it
+// depending on the mode of the cassette.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if c.Mode != ModeRecord {
+		rec, err := c.find(req, reqBody)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			return replay(req, rec), nil
+		}
+		if c.Mode == ModeReplay {
+			return nil, errors.Wrapf(ErrNoInteraction, "%s %s", req.Method, req.URL)
+		}
+	}
+	// Send the request, and record its interaction.
+	req = req.Clone(req.Context())
+	if reqBody != nil {
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	rec := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: c.redact(req.Header),
+			Body:   reqBody,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     c.redact(resp.Header),
+			Body:       respBody,
+		},
+	}
+	if err := c.record(rec); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// find returns the response of the first unused recorded interaction matching
+// the request, or of the last matching one if all have been used. It returns
+// nil if no recorded interaction matches.
+func (c *Cassette) find(req *http.Request, body []byte) (*RecordedResponse, error) {
+	matchers := c.Matchers
+	if len(matchers) == 0 {
+		matchers = []Matcher{MatchMethod, MatchURL}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	last := -1
+	for i, rec := range c.interactions {
+		matched := true
+		for _, match := range matchers {
+			if !match(req, body, &rec.Request) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if !c.used[i] {
+			c.used[i] = true
+			return &rec.Response, nil
+		}
+		last = i
+	}
+	if last == -1 {
+		return nil, nil
+	}
+	return &c.interactions[last].Response, nil
+}
+
+// record adds the interaction to the cassette and writes the cassette file.
+func (c *Cassette) record(rec *Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.interactions = append(c.interactions, rec)
+	c.used = append(c.used, true)
+	buf, err := json.MarshalIndent(c.interactions, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	return writeFileAtomic(c.Path, buf)
+}
+
+// redact returns a copy of the given headers with the values of the cassette's
+// redacted headers replaced.
+func (c *Cassette) redact(header http.Header) http.Header {
+	redact := c.Redact
+	if redact == nil {
+		redact = DefaultRedact
+	}
+	header = header.Clone()
+	for _, key := range redact {
+		values := header[http.CanonicalHeaderKey(key)]
+		for i := range values {
+			values[i] = Redacted
+		}
+	}
+	return header
+}
+
+// replay returns the recorded response to the request.
+func replay(req *http.Request, rec *RecordedResponse) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}
+}
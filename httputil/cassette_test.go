@@ -0,0 +1,102 @@
+package httputil_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewkiz/pkg/httputil"
+)
+
+func TestCassette(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		fmt.Fprintf(w, "%s %s %s", req.Method, req.URL.Path, body)
+	}))
+	cassettePath := filepath.Join(t.TempDir(), "fixture.json")
+
+	// Record interactions.
+	rec, err := httputil.NewCassette(cassettePath, httputil.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Matchers = []httputil.Matcher{httputil.MatchMethod, httputil.MatchURL, httputil.MatchBody}
+	sess := &httputil.Session{
+		Client:      &http.Client{Transport: rec},
+		BearerToken: "t0ken",
+	}
+	requests := []struct {
+		path, data string
+		want       string
+	}{
+		{path: "/a", want: "GET /a "},
+		{path: "/b", data: "foo", want: "POST /b foo"},
+		{path: "/b", data: "bar", want: "POST /b bar"},
+	}
+	do := func(path, data string) (string, error) {
+		if len(data) > 0 {
+			return sess.PostString(srv.URL+path, "text/plain", data)
+		}
+		return sess.GetString(srv.URL + path)
+	}
+	for _, r := range requests {
+		got, err := do(r.path, r.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != r.want {
+			t.Errorf("recorded response mismatch; expected %q, got %q", r.want, got)
+		}
+	}
+	srv.Close()
+
+	// Secrets are redacted from the cassette file.
+	buf, err := os.ReadFile(cassettePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"t0ken", "s3cr3t"} {
+		if strings.Contains(string(buf), secret) {
+			t.Errorf("cassette contains secret %q", secret)
+		}
+	}
+
+	// Replay interactions without network access, in reverse order.
+	play, err := httputil.NewCassette(cassettePath, httputil.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	play.Matchers = rec.Matchers
+	sess.Client = &http.Client{Transport: play}
+	for i := len(requests) - 1; i >= 0; i-- {
+		r := requests[i]
+		got, err := do(r.path, r.data)
+		if err != nil {
+			t.Errorf("unable to replay %q; %v", r.path, err)
+			continue
+		}
+		if got != r.want {
+			t.Errorf("replayed response mismatch; expected %q, got %q", r.want, got)
+		}
+	}
+	if _, err := do("/c", ""); !errors.Is(err, httputil.ErrNoInteraction) {
+		t.Errorf("error mismatch; expected %v, got %v", httputil.ErrNoInteraction, err)
+	}
+
+	// Cassettes created as struct literals load the cassette file on first use.
+	sess.Client = &http.Client{Transport: &httputil.Cassette{Path: cassettePath, Mode: httputil.ModeReplay}}
+	if got, err := do("/a", ""); err != nil || got != requests[0].want {
+		t.Errorf("replayed response mismatch; expected %q, got %q (%v)", requests[0].want, got, err)
+	}
+	sess.Client = &http.Client{Transport: &httputil.Cassette{Path: filepath.Join(t.TempDir(), "missing.json"), Mode: httputil.ModeReplay}}
+	if _, err := do("/a", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error mismatch; expected %v, got %v", os.ErrNotExist, err)
+	}
+}
@@ -0,0 +1,389 @@
+package httputil
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/osutil"
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// A Jar is a cookie jar which can be persisted to disk. It is backed by a
+// net/http/cookiejar.Jar using the public suffix list, and keeps track of the
+// attributes of each stored cookie, which cookiejar does not expose.
+//
+// Jar is safe for concurrent use.
+type Jar struct {
+	// Underlying cookie jar, which implements cookie matching.
+	jar *cookiejar.Jar
+	// Stored cookies, keyed by domain, path and name.
+	mu      sync.Mutex
+	entries map[jarKey]*jarEntry
+}
+
+// jarKey is the key of a stored cookie.
+type jarKey struct {
+	domain, path, name string
+}
+
+// jarEntry is a stored cookie, as persisted in JSON format.
+type jarEntry struct {
+	// Name of the cookie.
+	Name string `json:"name"`
+	// Value of the cookie.
+	Value string `json:"value"`
+	// Domain of the cookie, without leading dot.
+	Domain string `json:"domain"`
+	// The cookie is only sent to Domain itself, and not its subdomains.
+	HostOnly bool `json:"host_only"`
+	// Path of the cookie.
+	Path string `json:"path"`
+	// Expiry time of the cookie; zero for session cookies.
+	Expires time.Time `json:"expires,omitzero"`
+	// The cookie is only sent over HTTPS.
+	Secure bool `json:"secure"`
+	// The cookie is inaccessible to JavaScript.
+	HttpOnly bool `json:"http_only"`
+}
+
+// NewJar returns a new empty cookie jar.
+func NewJar() *Jar {
+	// cookiejar.New only fails on invalid options.
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &Jar{
+		jar:     jar,
+		entries: make(map[jarKey]*jarEntry),
+	}
+}
+
+// SetCookies handles the receipt of the cookies in a reply for the given URL.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, cookie := range cookies {
+		e, ok := newJarEntry(u, cookie, now)
+		if !ok {
+			continue
+		}
+		key := jarKey{domain: e.Domain, path: e.Path, name: e.Name}
+		if cookie.MaxAge < 0 || (!e.Expires.IsZero() && !e.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = e
+	}
+}
+
+// Cookies returns the cookies to send in a request for the given URL.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// All returns all unexpired cookies of the jar, sorted by domain, path and
+// name.
+func (j *Jar) All() []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, e := range j.sortedEntries() {
+		cookies = append(cookies, e.cookie())
+	}
+	return cookies
+}
+
+// sortedEntries returns the unexpired entries of the jar, sorted by domain,
+// path and name.
+func (j *Jar) sortedEntries() []*jarEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	var entries []*jarEntry
+	for key, e := range j.entries {
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			delete(j.entries, key)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b *jarEntry) int {
+		return cmp.Or(cmp.Compare(a.Domain, b.Domain), cmp.Compare(a.Path, b.Path), cmp.Compare(a.Name, b.Name))
+	})
+	return entries
+}
+
+// add adds the given persisted entries to the jar, skipping expired ones.
+func (j *Jar) add(entries []*jarEntry) {
+	now := time.Now()
+	for _, e := range entries {
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			continue
+		}
+		scheme := "http"
+		if e.Secure {
+			scheme = "https"
+		}
+		u := &url.URL{Scheme: scheme, Host: e.Domain, Path: e.Path}
+		j.SetCookies(u, []*http.Cookie{e.cookie()})
+	}
+}
+
+// newJarEntry returns the jar entry of the given cookie received from u. The
+// boolean return value is false if the cookie is not valid for u.
+//
+// The domain rules mirror those of cookiejar.Jar.SetCookies, so that the entry
+// records the cookie as stored by the underlying jar.
+func newJarEntry(u *url.URL, cookie *http.Cookie, now time.Time) (*jarEntry, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, false
+	}
+	host, err := idna.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return nil, false
+	}
+	host = strings.ToLower(host)
+	domain, hostOnly, ok := cookieDomain(host, cookie.Domain)
+	if !ok {
+		return nil, false
+	}
+	e := &jarEntry{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   domain,
+		HostOnly: hostOnly,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+	if len(e.Path) == 0 || e.Path[0] != '/' {
+		e.Path = defaultPath(u.Path)
+	}
+	switch {
+	case cookie.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case cookie.MaxAge == 0 && !cookie.Expires.IsZero():
+		e.Expires = cookie.Expires
+	}
+	return e, true
+}
+
+// cookieDomain returns the domain of a cookie with the given Domain attribute
+// received from host, and whether it is a host-only cookie. The boolean return
+// value is false if the Domain attribute is not valid for host.
+func cookieDomain(host, domain string) (string, bool, bool) {
+	if len(domain) == 0 {
+		return host, true, true
+	}
+	if strings.ContainsAny(host, ":%") || net.ParseIP(host) != nil {
+		// IP addresses have no subdomains; a Domain attribute equal to the
+		// host yields a host-only cookie.
+		return host, true, host == domain
+	}
+	domain = strings.TrimPrefix(domain, ".")
+	if len(domain) == 0 || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false, false
+	}
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= utf8.RuneSelf {
+			return "", false, false
+		}
+	}
+	domain = strings.ToLower(domain)
+	// Cookies may not be set on a public suffix, except as host-only cookies
+	// by the public suffix itself.
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		return host, true, host == domain
+	}
+	// The domain must domain-match the host.
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// defaultPath returns the default cookie path of the given URL path, as
+// specified by RFC 6265, section 5.1.4.
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// cookie returns the HTTP cookie of the jar entry.
+func (e *jarEntry) cookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+	if !e.HostOnly {
+		cookie.Domain = e.Domain
+	}
+	return cookie
+}
+
+// ReadFile reads cookies from a file specified by cookiePath into the jar. The
+// file format is determined by the file contents; JSON (as written by
+// WriteJSON) and Netscape cookies.txt are supported.
+func (j *Jar) ReadFile(cookiePath string) error {
+	buf, err := os.ReadFile(cookiePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	r := bytes.NewReader(buf)
+	if bytes.HasPrefix(bytes.TrimSpace(buf), []byte("[")) {
+		err = j.ReadJSON(r)
+	} else {
+		err = j.ReadNetscape(r)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse cookie file %q", cookiePath)
+	}
+	return nil
+}
+
+// WriteFile writes the cookies of the jar to a file specified by cookiePath.
+// The file format is determined by the file extension; JSON (.json) and Netscape
+// cookies.txt (any other extension) are supported. WriteFile creates the named
+// file using mode 0600, as cookies often hold credentials. The file is written
+// atomically; see osutil.WriteFileAtomic.
+func (j *Jar) WriteFile(cookiePath string) error {
+	write := j.WriteNetscape
+	if strings.ToLower(filepath.Ext(cookiePath)) == ".json" {
+		write = j.WriteJSON
+	}
+	return osutil.WriteFileAtomic(cookiePath, 0o600, write)
+}
+
+// ReadJSON reads cookies in JSON format from r into the jar.
+func (j *Jar) ReadJSON(r io.Reader) error {
+	var entries []*jarEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return errors.WithStack(err)
+	}
+	j.add(entries)
+	return nil
+}
+
+// WriteJSON writes the unexpired cookies of the jar in JSON format to w.
+func (j *Jar) WriteJSON(w io.Writer) error {
+	entries := j.sortedEntries()
+	if entries == nil {
+		entries = []*jarEntry{}
+	}
+	buf, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	if _, err := w.Write(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// httpOnlyPrefix is the line prefix of HttpOnly cookies in Netscape
+// cookies.txt files.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ReadNetscape reads cookies in Netscape cookies.txt format from r into the
+// jar.
+func (j *Jar) ReadNetscape(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	var entries []*jarEntry
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = line[len(httpOnlyPrefix):]
+		}
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			// Skip empty lines and comments.
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// Empty cookie value.
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return errors.Errorf("invalid number of fields on line %d; expected 7, got %d", lineNum, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid expiry time on line %d", lineNum)
+		}
+		e := &jarEntry{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires != 0 {
+			e.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	j.add(entries)
+	return nil
+}
+
+// WriteNetscape writes the unexpired cookies of the jar in Netscape cookies.txt
+// format to w. Session cookies are written with an expiry time of 0.
+func (j *Jar) WriteNetscape(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, e := range j.sortedEntries() {
+		if e.HttpOnly {
+			bw.WriteString(httpOnlyPrefix)
+		}
+		domain, subdomains := e.Domain, "FALSE"
+		if !e.HostOnly {
+			domain, subdomains = "."+e.Domain, "TRUE"
+		}
+		secure := "FALSE"
+		if e.Secure {
+			secure = "TRUE"
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, e.Path, secure, expires, e.Name, e.Value)
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package httputil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mewkiz/pkg/osutil"
+	"github.com/pkg/errors"
+)
+
+// DownloadOptions specifies the options of a download.
+type DownloadOptions struct {
+	// Progress is called after each chunk written to disk with the number of
+	// bytes downloaded so far, including any resumed part, and the total size
+	// (-1 if unknown).
+	Progress func(written, total int64)
+	// Resume a partial download left behind by a previous failed attempt,
+	// using a Range request. The ETag or Last-Modified validator of the
+	// original response is stored alongside the partial download, and sent in
+	// an If-Range header so that the download restarts from the beginning if
+	// the file has changed since. If the server does not support Range
+	// requests, the download also restarts from the beginning.
+	Resume bool
+	// Expected SHA-256 checksum of the file in hexadecimal; not verified if
+	// empty.
+	SHA256 string
+	// Maximum size of the file in bytes; no limit if 0.
+	MaxSize int64
+}
+
+// File name suffixes of partial downloads and of the validators of their
+// responses.
+const (
+	partSuffix      = ".part"
+	validatorSuffix = ".part.validator"
+)
+
+// Download downloads the specified URL to a file specified by dstPath. See
+// Session.Download for details.
+func Download(ctx context.Context, rawURL, dstPath string, opts *DownloadOptions) error {
+	return defaultSession.Download(ctx, rawURL, dstPath, opts)
+}
+
+// Download downloads the specified URL to a file specified by dstPath,
+// streaming the response body to disk. If opts is nil, default options are
+// used. The request uses the session's cookies and User-Agent.
+//
+// The file is written atomically; the response body is written to dstPath with
+// a ".part" suffix, which is synced to disk and renamed to dstPath once
+// complete and verified. On failure, the partial file is kept if opts.Resume is
+// set, and removed otherwise. If the server responds with a shorter range than
+// requested, the remainder is requested from where the range ended.
+//
+// Unlike other session requests, downloads have no default timeout; use ctx to
+// cancel a download.
+func (sess *Session) Download(ctx context.Context, rawURL, dstPath string, opts *DownloadOptions) (err error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	partPath, validatorPath := dstPath+partSuffix, dstPath+validatorSuffix
+	flag := os.O_RDWR | os.O_CREATE
+	if !opts.Resume {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(partPath, flag, 0o666)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if err != nil && !opts.Resume {
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+	}()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Validator of the response of the partial download; partial downloads
+	// without a validator are resumed unconditionally.
+	var validator string
+	if offset > 0 {
+		if buf, err := os.ReadFile(validatorPath); err == nil {
+			validator = string(buf)
+		}
+	}
+	accept := []int{http.StatusRequestedRangeNotSatisfiable}
+	for done := false; !done; {
+		req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if len(validator) > 0 {
+				req.Header.Set("If-Range", validator)
+			}
+		}
+		next := offset
+		err = sess.send(req, accept, func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusOK || (resp.StatusCode == http.StatusPartialContent && offset == 0) {
+				// The download (re)starts from the beginning; store the
+				// validator of the response for resuming it.
+				validator = responseValidator(resp)
+				if opts.Resume {
+					if err := storeValidator(f, validatorPath, validator); err != nil {
+						return err
+					}
+				}
+			}
+			var err error
+			next, done, err = writeBody(f, resp, offset, opts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		offset = next
+	}
+	if len(opts.SHA256) > 0 {
+		if err := verifySHA256(f, opts.SHA256); err != nil {
+			// Discard the corrupt download, as resuming it would not help.
+			f.Close()
+			f = nil
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+			return errors.Wrapf(err, "unable to verify download of %q", rawURL)
+		}
+	}
+	// CommitFile closes f, also on failure.
+	commit := f
+	f = nil
+	if err := osutil.CommitFile(commit, dstPath); err != nil {
+		return err
+	}
+	os.Remove(validatorPath)
+	return nil
+}
+
+// storeValidator stores the validator of a download restarting from the
+// beginning to validatorPath, removing it if empty. The partial download f is
+// truncated first, so that its previous contents are never associated with the
+// new validator.
+func storeValidator(f *os.File, validatorPath, validator string) error {
+	if err := f.Truncate(0); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(validator) == 0 {
+		if err := os.Remove(validatorPath); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	// Written atomically, as a torn validator would fail to match on resume and
+	// discard the partial download.
+	return writeFileAtomic(validatorPath, []byte(validator))
+}
+
+// responseValidator returns the validator of the response for use in an
+// If-Range header; i.e. its strong ETag, or else its Last-Modified date. It
+// returns an empty string if the response has neither.
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// writeBody writes the body of the download response to f, which holds offset
+// bytes of a previous partial download. It returns the size of the partial
+// download once written, and whether the download is complete; a partial
+// response may end before the end of the file.
+func writeBody(f *os.File, resp *http.Response, offset int64, opts *DownloadOptions) (written int64, done bool, err error) {
+	// Range of the response body, and total size of the file; end and total
+	// are -1 if unknown.
+	start, end, total := int64(0), int64(-1), int64(-1)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, end, total, err = parseContentRange(resp.Header.Get("Content-Range")); err != nil {
+			return 0, false, err
+		}
+		if start != offset {
+			return 0, false, errors.Errorf("Content-Range start mismatch; expected %d, got %d", offset, start)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download may already be complete.
+		_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset == 0 || err != nil || total != offset {
+			return 0, false, checkStatus(resp, nil)
+		}
+		if opts.Progress != nil {
+			opts.Progress(offset, total)
+		}
+		return offset, true, nil
+	default:
+		if resp.ContentLength >= 0 {
+			end, total = resp.ContentLength-1, resp.ContentLength
+		}
+	}
+	if opts.MaxSize > 0 && total > opts.MaxSize {
+		return 0, false, errors.Errorf("download size %d exceeds maximum size of %d bytes", total, opts.MaxSize)
+	}
+	// Discard data written by any previous failed attempt of this download.
+	if err := f.Truncate(start); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if opts.Progress != nil {
+		opts.Progress(start, total)
+	}
+	buf := make([]byte, 32*1024)
+	written = start
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if opts.MaxSize > 0 && written+int64(n) > opts.MaxSize {
+				return 0, false, errors.Errorf("download exceeds maximum size of %d bytes", opts.MaxSize)
+			}
+			if _, err := f.Write(buf[:n]); err != nil {
+				return 0, false, errors.WithStack(err)
+			}
+			written += int64(n)
+			if opts.Progress != nil {
+				opts.Progress(written, total)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, false, errors.WithStack(err)
+		}
+	}
+	if end >= 0 && written != end+1 {
+		return 0, false, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	return written, total < 0 || written >= total, nil
+}
+
+// parseContentRange parses the given Content-Range header value (e.g.
+// "bytes 100-199/200" or "bytes */200") and returns the first and last byte
+// positions (0 and -1 if unsatisfied) and the complete length (-1 if unknown).
+func parseContentRange(s string) (start, end, total int64, err error) {
+	rng, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, errors.Errorf("invalid Content-Range %q", s)
+	}
+	rng, size, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, 0, errors.Errorf("invalid Content-Range %q", s)
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, errors.Wrapf(err, "invalid Content-Range %q", s)
+		}
+	}
+	end = -1
+	if rng != "*" {
+		first, last, ok := strings.Cut(rng, "-")
+		if !ok {
+			return 0, 0, 0, errors.Errorf("invalid Content-Range %q", s)
+		}
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return 0, 0, 0, errors.Wrapf(err, "invalid Content-Range %q", s)
+		}
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+			return 0, 0, 0, errors.Wrapf(err, "invalid Content-Range %q", s)
+		}
+		if end < start || (total >= 0 && end >= total) {
+			return 0, 0, 0, errors.Errorf("invalid Content-Range %q", s)
+		}
+	}
+	return start, end, total, nil
+}
+
+// verifySHA256 verifies that the contents of f match the given hexadecimal
+// SHA-256 checksum.
+func verifySHA256(f *os.File, want string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.WithStack(err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return errors.Errorf("SHA-256 checksum mismatch; expected %s, got %s", strings.ToLower(want), got)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package httputil_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/pkg/httputil"
+)
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ranges = append(ranges, req.Header.Get("Range"))
+		http.ServeContent(w, req, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Complete download with progress and checksum verification.
+	dstPath := filepath.Join(dir, "a.bin")
+	var lastWritten, lastTotal int64
+	opts := &httputil.DownloadOptions{
+		Progress: func(written, total int64) { lastWritten, lastTotal = written, total },
+		SHA256:   checksum,
+	}
+	if err := httputil.Download(ctx, srv.URL, dstPath, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dstPath, content)
+	if n := int64(len(content)); lastWritten != n || lastTotal != n {
+		t.Errorf("progress mismatch; expected %d/%d, got %d/%d", n, n, lastWritten, lastTotal)
+	}
+
+	// Resume a partial download.
+	dstPath = filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(dstPath+".part", content[:1000], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ranges = nil
+	opts.Resume = true
+	if err := httputil.Download(ctx, srv.URL, dstPath, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dstPath, content)
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Errorf("Range header mismatch; expected [bytes=1000-], got %q", ranges)
+	}
+
+	// Checksum mismatch.
+	dstPath = filepath.Join(dir, "c.bin")
+	opts = &httputil.DownloadOptions{SHA256: strings.Repeat("0", 64)}
+	if err := httputil.Download(ctx, srv.URL, dstPath, opts); err == nil {
+		t.Errorf("expected error for checksum mismatch")
+	}
+	if _, err := os.Stat(dstPath); !os.IsNotExist(err) {
+		t.Errorf("expected no file after checksum mismatch; got %v", err)
+	}
+
+	// Maximum size exceeded.
+	dstPath = filepath.Join(dir, "d.bin")
+	opts = &httputil.DownloadOptions{MaxSize: 1000}
+	if err := httputil.Download(ctx, srv.URL, dstPath, opts); err == nil {
+		t.Errorf("expected error for download exceeding maximum size")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("directory entry count mismatch; expected 2, got %d", len(entries))
+	}
+}
+
+func TestDownloadResumeChanged(t *testing.T) {
+	version1 := bytes.Repeat([]byte("version 1;"), 10000)
+	version2 := bytes.Repeat([]byte("version 2;"), 10000)
+	content, etag := version1, `"v1"`
+	var ifRanges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ifRanges = append(ifRanges, req.Header.Get("If-Range"))
+		if req.URL.Path == "/truncated" {
+			// Send half of the body and close the connection.
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:len(content)/2])
+			return
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, req, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	dstPath := filepath.Join(t.TempDir(), "a.bin")
+	opts := &httputil.DownloadOptions{Resume: true}
+	if err := httputil.Download(ctx, srv.URL+"/truncated", dstPath, opts); err == nil {
+		t.Fatalf("expected error for truncated download")
+	}
+
+	// The file changes before the download is resumed.
+	content, etag = version2, `"v2"`
+	ifRanges = nil
+	if err := httputil.Download(ctx, srv.URL, dstPath, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dstPath, version2)
+	if len(ifRanges) != 1 || ifRanges[0] != `"v1"` {
+		t.Errorf("If-Range header mismatch; expected [%q], got %q", `"v1"`, ifRanges)
+	}
+}
+
+func TestDownloadShortRange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	const chunkSize = 50000
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Respond with at most chunkSize bytes of the requested range.
+		rng := req.Header.Get("Range")
+		ranges = append(ranges, rng)
+		var start int
+		fmt.Sscanf(rng, "bytes=%d-", &start)
+		end := min(start+chunkSize, len(content)) - 1
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end+1-start))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : end+1])
+	}))
+	defer srv.Close()
+	dstPath := filepath.Join(t.TempDir(), "a.bin")
+	if err := httputil.Download(context.Background(), srv.URL, dstPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dstPath, content)
+	want := []string{"", "bytes=50000-", "bytes=100000-", "bytes=150000-"}
+	if !slices.Equal(ranges, want) {
+		t.Errorf("Range header mismatch; expected %q, got %q", want, ranges)
+	}
+}
+
+// checkFile checks that the contents of the given file match want.
+func checkFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%q: contents mismatch; expected %d bytes, got %d bytes", path, len(want), len(got))
+	}
+}
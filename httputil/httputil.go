@@ -3,11 +3,16 @@ package httputil
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"golang.org/x/net/html"
 )
 
@@ -27,48 +32,62 @@ func SetClient(c *http.Client) {
 	client = c
 }
 
-// Post issues a POST to the specified URL.
+// DefaultTimeout is the default timeout of httputil requests, including the
+// time spent reading the response body. It applies to requests whose context
+// has no deadline; to use a different timeout for a single request, pass a
+// context with a deadline (e.g. from context.WithTimeout). No timeout is used
+// if DefaultTimeout is <= 0.
+var DefaultTimeout = 30 * time.Second
+
+// Post issues a POST to the specified URL. An *HTTPError is returned for
+// non-2xx responses.
 func Post(rawURL, bodyType, data string) (buf []byte, err error) {
-	resp, err := client.Post(rawURL, bodyType, strings.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	buf, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return buf, nil
+	return PostContext(context.Background(), rawURL, bodyType, data)
+}
+
+// PostContext issues a POST to the specified URL using the given context.
+func PostContext(ctx context.Context, rawURL, bodyType, data string) (buf []byte, err error) {
+	return defaultSession.PostContext(ctx, rawURL, bodyType, data)
 }
 
 // PostString issues a POST to the specified URL and returns the response as a
 // string.
 func PostString(rawURL, bodyType, data string) (s string, err error) {
-	buf, err := Post(rawURL, bodyType, data)
+	return PostStringContext(context.Background(), rawURL, bodyType, data)
+}
+
+// PostStringContext issues a POST to the specified URL using the given context
+// and returns the response as a string.
+func PostStringContext(ctx context.Context, rawURL, bodyType, data string) (s string, err error) {
+	buf, err := PostContext(ctx, rawURL, bodyType, data)
 	if err != nil {
 		return "", err
 	}
 	return string(buf), nil
 }
 
-// Get issues a GET to the specified URL and returns the raw response.
+// Get issues a GET to the specified URL and returns the raw response. An
+// *HTTPError is returned for non-2xx responses.
 func Get(rawURL string) (buf []byte, err error) {
-	resp, err := client.Get(rawURL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	buf, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return buf, nil
+	return GetContext(context.Background(), rawURL)
+}
+
+// GetContext issues a GET to the specified URL using the given context and
+// returns the raw response.
+func GetContext(ctx context.Context, rawURL string) (buf []byte, err error) {
+	return defaultSession.GetContext(ctx, rawURL)
 }
 
 // GetString issues a GET to the specified URL and returns the response as a
 // string.
 func GetString(rawURL string) (s string, err error) {
-	buf, err := Get(rawURL)
+	return GetStringContext(context.Background(), rawURL)
+}
+
+// GetStringContext issues a GET to the specified URL using the given context
+// and returns the response as a string.
+func GetStringContext(ctx context.Context, rawURL string) (s string, err error) {
+	buf, err := GetContext(ctx, rawURL)
 	if err != nil {
 		return "", err
 	}
@@ -77,93 +96,336 @@ func GetString(rawURL string) (s string, err error) {
 
 // GetDoc issues a GET request, parses it and returns an HTML node.
 func GetDoc(rawURL string) (doc *html.Node, err error) {
-	buf, err := Get(rawURL)
+	return GetDocContext(context.Background(), rawURL)
+}
+
+// GetDocContext issues a GET request using the given context, parses it and
+// returns an HTML node.
+func GetDocContext(ctx context.Context, rawURL string) (doc *html.Node, err error) {
+	buf, err := GetContext(ctx, rawURL)
 	if err != nil {
 		return nil, err
 	}
-	doc, err = html.Parse(bytes.NewReader(buf))
+	return parseDoc(buf)
+}
+
+// parseDoc parses the given HTML document.
+func parseDoc(buf []byte) (*html.Node, error) {
+	doc, err := html.Parse(bytes.NewReader(buf))
 	if err != nil {
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
 	return doc, nil
 }
 
-// A Session contains the cookies and User-Agent for a series of requests.
+// withTimeout returns a copy of ctx which is cancelled after the given timeout,
+// unless ctx already has a deadline or timeout is <= 0. The returned cancel
+// function must be called once the request and its response body are done.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// maxErrorBody is the maximum number of response body bytes kept by an
+// HTTPError.
+const maxErrorBody = 4096
+
+// An HTTPError is returned for responses with a non-2xx status code which was
+// not explicitly accepted.
+type HTTPError struct {
+	// HTTP method of the request.
+	Method string
+	// URL of the request.
+	URL string
+	// Status code of the response (e.g. 404).
+	StatusCode int
+	// Status line of the response (e.g. "404 Not Found").
+	Status string
+	// Headers of the response.
+	Header http.Header
+	// Body of the response, truncated to at most 4096 bytes.
+	Body []byte
+}
+
+// Error returns the error message of the HTTP error.
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// checkStatus returns an *HTTPError if the status code of the response is
+// neither 2xx nor one of the accepted status codes.
+func checkStatus(resp *http.Response, accept []int) error {
+	if resp.StatusCode/100 == 2 || slices.Contains(accept, resp.StatusCode) {
+		return nil
+	}
+	// Keep the start of the body for context; read errors are ignored as the
+	// status code is the primary error.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return errors.WithStack(&HTTPError{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	})
+}
+
+// defaultSession is the session used by the package-level httputil requests.
+var defaultSession = &Session{}
+
+// A Session contains the cookies, headers and credentials for a series of
+// requests. The zero value is a session without cookies using the default
+// httputil client, which is used by the package-level httputil requests.
 type Session struct {
-	// Cookies used for each session request.
+	// HTTP client of session requests. The default httputil client is used if
+	// Client is nil.
+	Client *http.Client
+	// Cookie jar of the session, updated from the Set-Cookie headers of
+	// responses. If non-nil, Jar replaces the cookie jar of Client.
+	Jar *Jar
+	// Cookies used for each session request, in addition to those of Jar.
 	Cookies []*http.Cookie
 	// The User-Agent of each session request. The default Go http User-Agent is
 	// used if UserAgent is empty.
 	UserAgent string
+	// Default headers of each session request.
+	Header http.Header
+	// Username and password used for HTTP basic authentication of each session
+	// request if Username is non-empty.
+	Username, Password string
+	// Token used for bearer authentication of each session request if
+	// non-empty. BearerToken takes precedence over basic authentication.
+	BearerToken string
+	// Non-2xx status codes accepted as successful responses of session
+	// requests. An *HTTPError is returned for any other non-2xx response.
+	AcceptStatus []int
+	// Timeout of each session request whose context has no deadline, covering
+	// all attempts. DefaultTimeout is used if Timeout is 0, and no timeout is
+	// used if Timeout is < 0.
+	Timeout time.Duration
+	// Retry policy of session requests. DefaultRetry is used if Retry is nil.
+	Retry *RetryPolicy
+	// Maximum rate of session requests per host, in requests per second; no
+	// limit if 0. Each attempt of a retried request counts.
+	RateLimit float64
+	// Maximum number of requests per host sent at once when below the rate
+	// limit; 1 if 0.
+	Burst int
+	// Maximum number of concurrent session requests per host; no limit if 0.
+	MaxConcurrent int
+	// Fetch the robots.txt of each host on first use, and limit the rate of
+	// session requests to the host according to its Crawl-delay.
+	Robots bool
+
+	// Rate and concurrency limiters of session requests, created on first use
+	// and shared by copies of the session made thereafter.
+	limits *hostLimits
+}
+
+// NewSession returns a new session with its own http client and an empty
+// cookie jar.
+func NewSession() *Session {
+	return &Session{
+		Client: &http.Client{},
+		Jar:    NewJar(),
+	}
 }
 
 // Get issues a GET to the specified URL and returns the raw response. The
 // request uses the session's cookies and User-Agent.
 func (sess *Session) Get(rawURL string) (buf []byte, err error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	for _, cookie := range sess.Cookies {
-		req.AddCookie(cookie)
-	}
-	if len(sess.UserAgent) != 0 {
-		req.Header.Set("User-Agent", sess.UserAgent)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	buf, err = ioutil.ReadAll(resp.Body)
+	return sess.GetContext(context.Background(), rawURL)
+}
+
+// GetContext issues a GET to the specified URL using the given context and
+// returns the raw response. The request uses the session's cookies and
+// User-Agent.
+func (sess *Session) GetContext(ctx context.Context, rawURL string) (buf []byte, err error) {
+	ctx, cancel := withTimeout(ctx, sess.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
-	return buf, nil
+	return sess.do(req)
 }
 
 // GetString issues a GET to the specified URL and returns the response as a
 // string. The request uses the session's cookies and User-Agent.
 func (sess *Session) GetString(rawURL string) (s string, err error) {
-	buf, err := sess.Get(rawURL)
+	return sess.GetStringContext(context.Background(), rawURL)
+}
+
+// GetStringContext issues a GET to the specified URL using the given context
+// and returns the response as a string. The request uses the session's cookies
+// and User-Agent.
+func (sess *Session) GetStringContext(ctx context.Context, rawURL string) (s string, err error) {
+	buf, err := sess.GetContext(ctx, rawURL)
 	if err != nil {
 		return "", err
 	}
 	return string(buf), nil
 }
 
+// GetDoc issues a GET request, parses it and returns an HTML node. The request
+// uses the session's cookies and User-Agent.
+func (sess *Session) GetDoc(rawURL string) (doc *html.Node, err error) {
+	return sess.GetDocContext(context.Background(), rawURL)
+}
+
+// GetDocContext issues a GET request using the given context, parses it and
+// returns an HTML node. The request uses the session's cookies and User-Agent.
+func (sess *Session) GetDocContext(ctx context.Context, rawURL string) (doc *html.Node, err error) {
+	buf, err := sess.GetContext(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseDoc(buf)
+}
+
 // Post issues a POST to the specified URL. The request uses the session's
 // cookies and User-Agent.
 func (sess *Session) Post(rawURL, bodyType, data string) (buf []byte, err error) {
-	req, err := http.NewRequest("POST", rawURL, strings.NewReader(data))
+	return sess.PostContext(context.Background(), rawURL, bodyType, data)
+}
+
+// PostContext issues a POST to the specified URL using the given context. The
+// request uses the session's cookies and User-Agent.
+func (sess *Session) PostContext(ctx context.Context, rawURL, bodyType, data string) (buf []byte, err error) {
+	ctx, cancel := withTimeout(ctx, sess.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, strings.NewReader(data))
 	if err != nil {
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
 	req.Header.Set("Content-Type", bodyType)
-	for _, cookie := range sess.Cookies {
-		req.AddCookie(cookie)
+	return sess.do(req)
+}
+
+// PostString issues a POST to the specified URL and returns the response as a
+// string. The request uses the session's cookies and User-Agent.
+func (sess *Session) PostString(rawURL, bodyType, data string) (s string, err error) {
+	return sess.PostStringContext(context.Background(), rawURL, bodyType, data)
+}
+
+// PostStringContext issues a POST to the specified URL using the given context
+// and returns the response as a string. The request uses the session's cookies
+// and User-Agent.
+func (sess *Session) PostStringContext(ctx context.Context, rawURL, bodyType, data string) (s string, err error) {
+	buf, err := sess.PostContext(ctx, rawURL, bodyType, data)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// timeout returns the timeout of session requests.
+func (sess *Session) timeout() time.Duration {
+	if sess.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return sess.Timeout
+}
+
+// httpClient returns the http client of session requests.
+func (sess *Session) httpClient() *http.Client {
+	c := sess.Client
+	if c == nil {
+		c = client
+	}
+	if sess.Jar != nil && c.Jar != sess.Jar {
+		cc := *c
+		cc.Jar = sess.Jar
+		c = &cc
+	}
+	return c
+}
+
+// prepare adds the session's headers, credentials and cookies to the request.
+func (sess *Session) prepare(req *http.Request) {
+	for key, values := range sess.Header {
+		if len(req.Header.Values(key)) == 0 {
+			req.Header[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+		}
 	}
 	if len(sess.UserAgent) != 0 {
 		req.Header.Set("User-Agent", sess.UserAgent)
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	switch {
+	case len(sess.BearerToken) != 0:
+		req.Header.Set("Authorization", "Bearer "+sess.BearerToken)
+	case len(sess.Username) != 0:
+		req.SetBasicAuth(sess.Username, sess.Password)
 	}
-	defer resp.Body.Close()
-	buf, err = ioutil.ReadAll(resp.Body)
+	for _, cookie := range sess.Cookies {
+		req.AddCookie(cookie)
+	}
+}
+
+// do sends the request with the session's headers, credentials and cookies,
+// retrying it according to the session's retry policy, and returns the
+// response body. An *HTTPError is returned if the status code of the response
+// is neither 2xx nor one of the accepted status codes.
+func (sess *Session) do(req *http.Request) ([]byte, error) {
+	var buf []byte
+	err := sess.send(req, sess.AcceptStatus, func(resp *http.Response) error {
+		var err error
+		if buf, err = io.ReadAll(resp.Body); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	return buf, nil
 }
 
-// PostString issues a POST to the specified URL and returns the response as a
-// string. The request uses the session's cookies and User-Agent.
-func (sess *Session) PostString(rawURL, bodyType, data string) (s string, err error) {
-	buf, err := sess.Post(rawURL, bodyType, data)
+// send sends the request with the session's headers, credentials and cookies,
+// and calls handle with the response if its status code is 2xx or one of the
+// accepted status codes. Failed attempts, including errors returned by handle,
+// are retried according to the session's retry policy.
+func (sess *Session) send(req *http.Request, accept []int, handle func(resp *http.Response) error) error {
+	sess.prepare(req)
+	policy := sess.Retry
+	if policy == nil {
+		policy = DefaultRetry
+	}
+	for attempt := 1; ; attempt++ {
+		err := sess.sendOnce(req, accept, handle)
+		if err == nil {
+			return nil
+		}
+		delay, ok := policy.retry(req, attempt, err)
+		if !ok {
+			return err
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return err
+		}
+		if req, err = rewind(req); err != nil {
+			return err
+		}
+	}
+}
+
+// sendOnce sends the request once and calls handle with the response.
+func (sess *Session) sendOnce(req *http.Request, accept []int, handle func(resp *http.Response) error) error {
+	release, err := sess.acquire(req)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(buf), nil
+	defer release()
+	resp, err := sess.httpClient().Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, accept); err != nil {
+		return err
+	}
+	return handle(resp)
 }
@@ -0,0 +1,300 @@
+package httputil_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/pkg/httputil"
+)
+
+func TestGetContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("<p>hello</p>"))
+	}))
+	defer srv.Close()
+
+	s, err := httputil.GetStringContext(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>hello</p>"; s != want {
+		t.Errorf("response mismatch; expected %q, got %q", want, s)
+	}
+
+	// Per-call timeout through the context deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := httputil.GetContext(ctx, srv.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch; expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// Session timeout, used when the context has no deadline.
+	sess := &httputil.Session{Timeout: 50 * time.Millisecond}
+	if _, err := sess.GetDocContext(context.Background(), srv.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch; expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if _, err := sess.GetDoc(srv.URL); err != nil {
+		t.Errorf("unable to get document; %v", err)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Reason", "missing")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(strings.Repeat("x", 10000)))
+	}))
+	defer srv.Close()
+
+	_, err := httputil.Get(srv.URL + "/foo")
+	var herr *httputil.HTTPError
+	if !errors.As(err, &herr) {
+		t.Fatalf("error type mismatch; expected *httputil.HTTPError, got %T", err)
+	}
+	if herr.StatusCode != http.StatusNotFound {
+		t.Errorf("status code mismatch; expected %d, got %d", http.StatusNotFound, herr.StatusCode)
+	}
+	if want := srv.URL + "/foo"; herr.URL != want {
+		t.Errorf("URL mismatch; expected %q, got %q", want, herr.URL)
+	}
+	if got := herr.Header.Get("X-Reason"); got != "missing" {
+		t.Errorf("header mismatch; expected %q, got %q", "missing", got)
+	}
+	if len(herr.Body) != 4096 {
+		t.Errorf("body length mismatch; expected 4096, got %d", len(herr.Body))
+	}
+
+	sess := &httputil.Session{AcceptStatus: []int{http.StatusNotFound}}
+	buf, err := sess.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error for accepted status code; %v", err)
+	}
+	if len(buf) != 10000 {
+		t.Errorf("body length mismatch; expected 10000, got %d", len(buf))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := attempts.Add(1)
+		switch {
+		case req.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case n == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case n == 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			body, _ := io.ReadAll(req.Body)
+			w.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	sess := &httputil.Session{
+		Retry: &httputil.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5, RetryNonIdempotent: true},
+	}
+	s, err := sess.PostString(srv.URL, "text/plain", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "foo" {
+		t.Errorf("response mismatch; expected %q, got %q", "foo", s)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("attempt count mismatch; expected 3, got %d", n)
+	}
+
+	// Client errors are not retried.
+	attempts.Store(2)
+	if _, err := sess.Get(srv.URL + "/missing"); err == nil {
+		t.Errorf("expected error for missing page")
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("attempt count mismatch; expected 1 attempt, got %d", n-2)
+	}
+
+	// Attempts are limited by MaxAttempts.
+	attempts.Store(0)
+	sess.Retry.MaxAttempts = 2
+	var herr *httputil.HTTPError
+	if _, err := sess.Get(srv.URL); !errors.As(err, &herr) || herr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("error mismatch; expected 503 HTTPError, got %v", err)
+	}
+
+	// Non-idempotent requests are not retried unless opted in.
+	attempts.Store(1)
+	sess.Retry.RetryNonIdempotent = false
+	if _, err := sess.PostString(srv.URL, "text/plain", "foo"); !errors.As(err, &herr) || herr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("error mismatch; expected 503 HTTPError, got %v", err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("attempt count mismatch; expected 1 attempt, got %d", n-1)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip executes a single HTTP transaction.
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransportError(t *testing.T) {
+	golden := []struct {
+		err      error
+		attempts int32
+	}{
+		// Not transient.
+		{err: x509.UnknownAuthorityError{}, attempts: 1},
+		{err: errors.New("foo"), attempts: 1},
+		// Transient.
+		{err: syscall.ECONNRESET, attempts: 3},
+		{err: syscall.ECONNREFUSED, attempts: 3},
+		{err: io.ErrUnexpectedEOF, attempts: 3},
+	}
+	for _, g := range golden {
+		var attempts atomic.Int32
+		sess := &httputil.Session{
+			Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				attempts.Add(1)
+				return nil, g.err
+			})},
+			Retry: &httputil.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+		}
+		if _, err := sess.Get("http://example.org/"); err == nil {
+			t.Errorf("%v: expected error", g.err)
+		}
+		if n := attempts.Load(); n != g.attempts {
+			t.Errorf("%v: attempt count mismatch; expected %d, got %d", g.err, g.attempts, n)
+		}
+	}
+}
+
+func TestSessionCookies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Path: "/", MaxAge: 3600})
+			http.Redirect(w, req, "/home", http.StatusFound)
+		case "/home":
+			user, pass, _ := req.BasicAuth()
+			cookie, err := req.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, "%s %s:%s %s", cookie.Value, user, pass, req.Header.Get("X-Foo"))
+		}
+	}))
+	defer srv.Close()
+
+	sess := httputil.NewSession()
+	sess.Header = http.Header{"X-Foo": {"bar"}}
+	sess.Username, sess.Password = "alice", "pw"
+	s, err := sess.GetString(srv.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "s3cr3t alice:pw bar"; s != want {
+		t.Errorf("response mismatch; expected %q, got %q", want, s)
+	}
+
+	// Persist cookies and load them into a new session.
+	dir := t.TempDir()
+	for _, name := range []string{"cookies.json", "cookies.txt"} {
+		cookiePath := filepath.Join(dir, name)
+		if err := sess.Jar.WriteFile(cookiePath); err != nil {
+			t.Fatalf("%q: unable to write cookies; %v", name, err)
+		}
+		jar := httputil.NewJar()
+		if err := jar.ReadFile(cookiePath); err != nil {
+			t.Fatalf("%q: unable to read cookies; %v", name, err)
+		}
+		got, want := jar.All(), sess.Jar.All()
+		if len(got) != 2 || len(want) != 2 {
+			t.Fatalf("%q: cookie count mismatch; expected 2, got %d and %d", name, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].String() != want[i].String() || !got[i].Expires.Truncate(time.Second).Equal(want[i].Expires.Truncate(time.Second)) {
+				t.Errorf("%q: cookie %d mismatch; expected %v, got %v", name, i, want[i], got[i])
+			}
+		}
+		sess2 := &httputil.Session{Jar: jar}
+		if _, err := sess2.Get(srv.URL + "/home"); err != nil {
+			t.Errorf("%q: unable to use loaded cookies; %v", name, err)
+		}
+	}
+}
+
+func TestJarDomain(t *testing.T) {
+	golden := []struct {
+		url    string
+		domain string
+		// Domain of the stored cookie; empty for host-only cookies.
+		want string
+		ok   bool
+	}{
+		{url: "http://www.example.com/", domain: "", want: "", ok: true},
+		{url: "http://www.example.com/", domain: "example.com", want: "example.com", ok: true},
+		{url: "http://www.example.com/", domain: ".EXAMPLE.com", want: "example.com", ok: true},
+		{url: "http://www.example.com./", domain: "example.com", want: "example.com", ok: true},
+		{url: "http://www.example.com/", domain: "other.com", ok: false},
+		{url: "http://www.example.com/", domain: "example.com.", ok: false},
+		// IP addresses yield host-only cookies.
+		{url: "http://127.0.0.1/", domain: "127.0.0.1", want: "", ok: true},
+		{url: "http://127.0.0.1/", domain: ".127.0.0.1", ok: false},
+		// Public suffixes.
+		{url: "http://co.uk/", domain: "co.uk", want: "", ok: true},
+		{url: "http://www.example.co.uk/", domain: "co.uk", ok: false},
+		{url: "ftp://www.example.com/", domain: "", ok: false},
+	}
+	for _, g := range golden {
+		u, err := url.Parse(g.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jar := httputil.NewJar()
+		jar.SetCookies(u, []*http.Cookie{{Name: "foo", Value: "bar", Domain: g.domain}})
+		all := jar.All()
+		if len(all) != len(jar.Cookies(u)) {
+			t.Errorf("%s with domain %q: cookie count mismatch of jar and cookiejar; %d and %d", g.url, g.domain, len(all), len(jar.Cookies(u)))
+		}
+		if !g.ok {
+			if len(all) != 0 {
+				t.Errorf("%s with domain %q: expected cookie to be rejected, got %v", g.url, g.domain, all)
+			}
+			continue
+		}
+		if len(all) != 1 {
+			t.Errorf("%s with domain %q: cookie count mismatch; expected 1, got %d", g.url, g.domain, len(all))
+			continue
+		}
+		if all[0].Domain != g.want {
+			t.Errorf("%s with domain %q: domain mismatch; expected %q, got %q", g.url, g.domain, g.want, all[0].Domain)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package httputil
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// hostLimits holds the limiters of a session's requests, keyed by host. It is
+// kept behind a pointer so that sessions may be copied.
+type hostLimits struct {
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// limitsMu guards the creation of the limiters of sessions.
+var limitsMu sync.Mutex
+
+// hostLimiter limits the rate and concurrency of requests to a host.
+type hostLimiter struct {
+	// Token bucket; the number of available tokens, which may be negative when
+	// requests are waiting, as of the last update.
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// Semaphore of concurrent requests; nil if unlimited.
+	sem chan struct{}
+	// Crawl-delay of the host's robots.txt, fetched on first use. A failed
+	// fetch is retried by requests made robotsRetry after it failed.
+	robotsMu      sync.Mutex
+	robotsFetched bool
+	robotsFailed  time.Time
+	crawlDelay    time.Duration
+	// Closed once the current fetch of robots.txt completes; nil if no fetch is
+	// in progress.
+	robotsDone chan struct{}
+}
+
+// limiter returns the limiter of requests to the given host, or nil if the
+// session has no limits.
+func (sess *Session) limiter(host string) *hostLimiter {
+	if sess.RateLimit <= 0 && sess.MaxConcurrent <= 0 && !sess.Robots {
+		return nil
+	}
+	host = strings.ToLower(host)
+	limitsMu.Lock()
+	if sess.limits == nil {
+		sess.limits = &hostLimits{hosts: make(map[string]*hostLimiter)}
+	}
+	limits := sess.limits
+	limitsMu.Unlock()
+	limits.mu.Lock()
+	defer limits.mu.Unlock()
+	l, ok := limits.hosts[host]
+	if !ok {
+		l = &hostLimiter{tokens: float64(max(sess.Burst, 1)), last: time.Now()}
+		if sess.MaxConcurrent > 0 {
+			l.sem = make(chan struct{}, sess.MaxConcurrent)
+		}
+		limits.hosts[host] = l
+	}
+	return l
+}
+
+// acquire waits until the request may be sent according to the session's
+// rate and concurrency limits. The returned release function must be called
+// once the response body is done.
+func (sess *Session) acquire(req *http.Request) (release func(), err error) {
+	l := sess.limiter(req.URL.Host)
+	if l == nil {
+		return func() {}, nil
+	}
+	ctx := req.Context()
+	rate, burst := sess.RateLimit, max(sess.Burst, 1)
+	if sess.Robots {
+		crawlDelay, err := l.robotsCrawlDelay(sess, req)
+		if err != nil {
+			return nil, err
+		}
+		if crawlDelay > 0 {
+			if r := 1 / crawlDelay.Seconds(); rate <= 0 || r < rate {
+				rate, burst = r, 1
+			}
+		}
+	}
+	if rate > 0 {
+		if err := l.wait(ctx, rate, burst); err != nil {
+			return nil, err
+		}
+	}
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	}
+}
+
+// wait reserves a token of the token bucket, which is refilled at the given
+// rate per second up to burst tokens, and waits until it is available.
+func (l *hostLimiter) wait(ctx context.Context, rate float64, burst int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(float64(burst), l.tokens+now.Sub(l.last).Seconds()*rate)
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		// Return the unused token.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// robotsRetry is the duration after a failed robots.txt fetch during which the
+// fetch is not retried.
+const robotsRetry = time.Minute
+
+// robotsCrawlDelay returns the Crawl-delay of the robots.txt of the host of the
+// request, fetching it unless already fetched. Concurrent requests share a
+// single fetch, and wait for it until their context is cancelled. A failed
+// fetch yields no delay, and is retried once robotsRetry has passed.
+func (l *hostLimiter) robotsCrawlDelay(sess *Session, req *http.Request) (time.Duration, error) {
+	ctx := req.Context()
+	for {
+		l.robotsMu.Lock()
+		if l.robotsFetched || (!l.robotsFailed.IsZero() && time.Since(l.robotsFailed) < robotsRetry) {
+			crawlDelay := l.crawlDelay
+			l.robotsMu.Unlock()
+			return crawlDelay, nil
+		}
+		done := l.robotsDone
+		if done == nil {
+			// Fetch outside of the lock, detached from the request, as the
+			// result applies to all requests to the host.
+			done = make(chan struct{})
+			l.robotsDone = done
+			go func() {
+				crawlDelay, ok := sess.fetchCrawlDelay(req)
+				l.robotsMu.Lock()
+				l.crawlDelay, l.robotsFetched = crawlDelay, ok
+				if !ok {
+					l.robotsFailed = time.Now()
+				}
+				l.robotsDone = nil
+				l.robotsMu.Unlock()
+				close(done)
+			}()
+		}
+		l.robotsMu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return 0, errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// maxRobotsSize is the maximum size of robots.txt files read.
+const maxRobotsSize = 512 * 1024
+
+// robotsTimeout is the timeout of robots.txt requests.
+const robotsTimeout = 10 * time.Second
+
+// fetchCrawlDelay fetches the robots.txt of the host of the request and
+// returns the Crawl-delay which applies to the session's User-Agent. It returns
+// 0 if robots.txt is unavailable or specifies no Crawl-delay. The boolean
+// return value is false if robots.txt could not be fetched (e.g. network or
+// server errors), as opposed to being absent.
+//
+// The robots.txt request is detached from the cancellation of req, as its
+// result applies to all requests to the host.
+func (sess *Session) fetchCrawlDelay(req *http.Request) (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), robotsTimeout)
+	defer cancel()
+	robotsURL := req.URL.Scheme + "://" + req.URL.Host + "/robots.txt"
+	robotsReq, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return 0, false
+	}
+	if len(sess.UserAgent) != 0 {
+		robotsReq.Header.Set("User-Agent", sess.UserAgent)
+	}
+	resp, err := sess.httpClient().Do(robotsReq)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseCrawlDelay(io.LimitReader(resp.Body, maxRobotsSize), sess.UserAgent), true
+	case resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests:
+		// Server errors may be temporary.
+		return 0, false
+	default:
+		return 0, true
+	}
+}
+
+// parseCrawlDelay parses the given robots.txt file and returns the Crawl-delay
+// of the group matching the product token of the given User-Agent, or of the
+// "*" group if no group matches.
+func parseCrawlDelay(r io.Reader, userAgent string) time.Duration {
+	// Product token of the User-Agent (e.g. "foobot" of "FooBot/1.0").
+	token, _, _ := strings.Cut(strings.ToLower(userAgent), "/")
+	token = strings.TrimSpace(token)
+	var (
+		// User-agents of the current group.
+		agents []string
+		// The previous line was a User-agent line.
+		inAgents bool
+		// Crawl-delay of the matching and "*" groups; -1 if not present.
+		match, wildcard = time.Duration(-1), time.Duration(-1)
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "user-agent" {
+			if !inAgents {
+				agents = nil
+			}
+			agents = append(agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		}
+		inAgents = false
+		if key != "crawl-delay" {
+			continue
+		}
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil || secs < 0 {
+			continue
+		}
+		delay := time.Duration(secs * float64(time.Second))
+		for _, agent := range agents {
+			switch {
+			case agent == "*":
+				wildcard = delay
+			case len(token) > 0 && token == agent:
+				match = delay
+			}
+		}
+	}
+	if match >= 0 {
+		return match
+	}
+	return max(wildcard, 0)
+}
@@ -0,0 +1,131 @@
+package httputil_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/pkg/httputil"
+)
+
+func TestRateLimit(t *testing.T) {
+	var cur, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: foobot\nCrawl-delay: 0.05\n\nUser-agent: bot\nCrawl-delay: 10\n\nUser-agent:\nCrawl-delay: 10\n\nUser-agent: *\nCrawl-delay: 10\n")
+			return
+		}
+		n := cur.Add(1)
+		defer cur.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	golden := []struct {
+		name string
+		sess *httputil.Session
+		// Minimum duration of 5 requests.
+		min time.Duration
+		// Maximum number of concurrent requests.
+		peak int32
+	}{
+		{name: "rate", sess: &httputil.Session{RateLimit: 20}, min: 200 * time.Millisecond, peak: 5},
+		{name: "concurrency", sess: &httputil.Session{MaxConcurrent: 2}, peak: 2},
+		{name: "robots", sess: &httputil.Session{Robots: true, UserAgent: "FooBot/1.0"}, min: 200 * time.Millisecond, peak: 5},
+	}
+	// A session is copyable, and a cancelled first request does not prevent the
+	// robots.txt of the host from being fetched.
+	robots := *golden[2].sess
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := robots.GetContext(ctx, srv.URL); err == nil {
+		t.Errorf("expected error for cancelled request")
+	}
+	golden = append(golden, struct {
+		name string
+		sess *httputil.Session
+		min  time.Duration
+		peak int32
+	}{name: "robots after cancel", sess: &robots, min: 200 * time.Millisecond, peak: 5})
+	for _, g := range golden {
+		peak.Store(0)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := g.sess.Get(srv.URL); err != nil {
+					t.Errorf("%s: unable to get page; %v", g.name, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if elapsed := time.Since(start); elapsed < g.min {
+			t.Errorf("%s: duration too short; expected >= %v, got %v", g.name, g.min, elapsed)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: duration too long; got %v", g.name, elapsed)
+		}
+		if p := peak.Load(); p > g.peak {
+			t.Errorf("%s: concurrent request count mismatch; expected <= %d, got %d", g.name, g.peak, p)
+		}
+	}
+}
+
+func TestRobotsFailure(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			fetches.Add(1)
+			// Delay the response, so that concurrent requests wait for it.
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	// A failed robots.txt fetch is shared by concurrent requests, and not
+	// retried by subsequent requests made shortly after.
+	sess := &httputil.Session{Robots: true, UserAgent: "FooBot/1.0"}
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sess.Get(srv.URL); err != nil {
+				t.Errorf("unable to get page; %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	for range 5 {
+		if _, err := sess.Get(srv.URL); err != nil {
+			t.Errorf("unable to get page; %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetch count mismatch; expected 1, got %d", n)
+	}
+
+	// A request waiting for robots.txt is cancelled by its context.
+	sess = &httputil.Session{Robots: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := sess.GetContext(ctx, srv.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch; expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,163 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A RetryPolicy specifies how failed requests are retried. Requests are retried
+// on transient network errors (timeouts, refused or reset connections and
+// connections closed before the response was complete), 5xx responses and 429
+// (Too Many Requests) responses, with exponential backoff between attempts.
+//
+// Only requests which are safe to repeat are retried; i.e. requests with an
+// idempotent method (GET, HEAD, OPTIONS, TRACE, PUT or DELETE) or an
+// Idempotency-Key header, and no body or a body which can be replayed.
+// Requests of other methods (e.g. POST) are only retried if
+// RetryNonIdempotent is set.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first. The request is not
+	// retried if MaxAttempts is <= 1.
+	MaxAttempts int
+	// Delay before the first retry, doubled for each subsequent retry; 1s if 0.
+	BaseDelay time.Duration
+	// Maximum delay between attempts; 30s if 0. If the Retry-After header of a
+	// response requests a longer delay, the request is not retried.
+	MaxDelay time.Duration
+	// Fraction in [0, 1] of each backoff delay which is randomised, to avoid
+	// retries of concurrent clients in lockstep.
+	Jitter float64
+	// Retry requests of non-idempotent methods (e.g. POST) without an
+	// Idempotency-Key header, provided that their body can be replayed. The
+	// server may process such requests more than once.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetry is the retry policy of httputil requests, and of sessions
+// without a retry policy. Requests are not retried if DefaultRetry is nil.
+var DefaultRetry *RetryPolicy
+
+// retry reports whether the request should be retried after the given failed
+// attempt (starting at 1) with error err, and if so, the delay before the next
+// attempt.
+func (policy *RetryPolicy) retry(req *http.Request, attempt int, err error) (time.Duration, bool) {
+	if policy == nil || attempt >= policy.MaxAttempts || !policy.replayable(req) || req.Context().Err() != nil {
+		return 0, false
+	}
+	var retryAfter time.Duration
+	var herr *HTTPError
+	switch {
+	case errors.As(err, &herr):
+		if herr.StatusCode != http.StatusTooManyRequests && herr.StatusCode/100 != 5 {
+			return 0, false
+		}
+		retryAfter = parseRetryAfter(herr.Header.Get("Retry-After"))
+	case !isTransient(err):
+		return 0, false
+	}
+	baseDelay := policy.BaseDelay
+	if baseDelay == 0 {
+		baseDelay = 1 * time.Second
+	}
+	maxDelay := policy.MaxDelay
+	if maxDelay == 0 {
+		maxDelay = 30 * time.Second
+	}
+	if retryAfter > maxDelay {
+		return 0, false
+	}
+	delay := maxDelay
+	if shift := attempt - 1; shift < 32 && baseDelay<<shift < maxDelay {
+		delay = baseDelay << shift
+	}
+	if policy.Jitter > 0 {
+		delay -= time.Duration(policy.Jitter * rand.Float64() * float64(delay))
+	}
+	return max(delay, retryAfter), true
+}
+
+// replayable reports whether the request is safe to send again according to
+// the retry policy.
+func (policy *RetryPolicy) replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+	return policy.RetryNonIdempotent
+}
+
+// isTransient reports whether the given request error is a network error which
+// may succeed if retried.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// Every error of http.Client.Do is a *url.Error, which implements
+	// net.Error itself; classify the underlying error instead.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseRetryAfter parses the given Retry-After header value, either a number of
+// seconds or an HTTP date, and returns the requested delay. It returns 0 if the
+// value is empty or invalid.
+func parseRetryAfter(s string) time.Duration {
+	if len(s) == 0 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return max(0, time.Duration(secs)*time.Second)
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return max(0, time.Until(t))
+	}
+	return 0
+}
+
+// sleep waits for the given duration, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// rewind returns a copy of the request with its body reset, for sending it
+// again.
+func rewind(req *http.Request) (*http.Request, error) {
+	req = req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		req.Body = body
+	}
+	return req, nil
+}
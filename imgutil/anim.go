@@ -0,0 +1,601 @@
+package imgutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// An Animation is a sequence of image frames.
+type Animation struct {
+	// Frames of the animation.
+	Frames []Frame
+	// Bounds of the canvas onto which frames are drawn.
+	Bounds image.Rectangle
+	// Number of times the animation is played; 0 means forever.
+	Loops int
+}
+
+// A Frame is a frame of an animation.
+type Frame struct {
+	// Image of the frame, positioned within the canvas by its bounds.
+	Image image.Image
+	// Duration of the frame.
+	Delay time.Duration
+	// Disposal of the frame region before rendering the next frame.
+	Disposal Disposal
+	// Blending of the frame with the canvas.
+	Blend Blend
+}
+
+// Disposal specifies how the frame region is disposed of before rendering the
+// next frame.
+type Disposal uint8
+
+// Disposal methods.
+const (
+	// DisposalNone leaves the canvas as is.
+	DisposalNone Disposal = iota
+	// DisposalBackground clears the frame region to fully transparent.
+	DisposalBackground
+	// DisposalPrevious restores the frame region to its state before the frame
+	// was rendered.
+	DisposalPrevious
+)
+
+// Blend specifies how a frame is blended with the canvas.
+type Blend uint8
+
+// Blend operations.
+const (
+	// BlendOver alpha-composites the frame over the canvas.
+	BlendOver Blend = iota
+	// BlendSource replaces the frame region of the canvas with the frame.
+	BlendSource
+)
+
+// Composite renders the frames of the animation onto its canvas, applying the
+// blend and disposal of each frame, and returns the resulting full images.
+func (anim *Animation) Composite() []*image.NRGBA {
+	canvas := image.NewNRGBA(anim.Bounds)
+	var prev *image.NRGBA
+	var imgs []*image.NRGBA
+	for _, frame := range anim.Frames {
+		fr := frame.Image.Bounds()
+		if frame.Disposal == DisposalPrevious {
+			prev = image.NewNRGBA(fr)
+			draw.Draw(prev, fr, canvas, fr.Min, draw.Src)
+		}
+		op := draw.Over
+		if frame.Blend == BlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, fr, frame.Image, fr.Min, op)
+		img := image.NewNRGBA(anim.Bounds)
+		copy(img.Pix, canvas.Pix)
+		imgs = append(imgs, img)
+		switch frame.Disposal {
+		case DisposalBackground:
+			draw.Draw(canvas, fr, image.Transparent, image.Point{}, draw.Src)
+		case DisposalPrevious:
+			draw.Draw(canvas, fr, prev, fr.Min, draw.Src)
+		}
+	}
+	return imgs
+}
+
+// ReadGIF reads all frames of an animated GIF image from r.
+func ReadGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	anim := &Animation{
+		Bounds: image.Rect(0, 0, g.Config.Width, g.Config.Height),
+	}
+	switch {
+	case g.LoopCount == 0:
+		anim.Loops = 0
+	case g.LoopCount < 0:
+		anim.Loops = 1
+	default:
+		anim.Loops = g.LoopCount + 1
+	}
+	for i, img := range g.Image {
+		frame := Frame{
+			Image: img,
+			Delay: time.Duration(g.Delay[i]) * 10 * time.Millisecond,
+		}
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				frame.Disposal = DisposalBackground
+			case gif.DisposalPrevious:
+				frame.Disposal = DisposalPrevious
+			}
+		}
+		anim.Frames = append(anim.Frames, frame)
+	}
+	return anim, nil
+}
+
+// ReadGIFFile reads all frames of an animated GIF file specified by imgPath.
+func ReadGIFFile(imgPath string) (*Animation, error) {
+	f, err := os.Open(imgPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	anim, err := ReadGIF(bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode %q", imgPath)
+	}
+	return anim, nil
+}
+
+// WriteGIF writes the animation as an animated GIF image to w. Frames which are
+// not paletted images are quantised to 256 colours using MedianCut. GIF frames
+// are always blended over the canvas, and delays are rounded to hundredths of a
+// second.
+func WriteGIF(w io.Writer, anim *Animation) error {
+	g := &gif.GIF{
+		Config: image.Config{
+			Width:  anim.Bounds.Dx(),
+			Height: anim.Bounds.Dy(),
+		},
+	}
+	switch {
+	case anim.Loops == 0:
+		g.LoopCount = 0
+	case anim.Loops == 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = anim.Loops - 1
+	}
+	for _, frame := range anim.Frames {
+		img, ok := frame.Image.(*image.Paletted)
+		if !ok {
+			img = Quantize(frame.Image, 256, false)
+		}
+		// GIF frames are positioned relative to the canvas origin.
+		if anim.Bounds.Min != (image.Point{}) {
+			shifted := *img
+			shifted.Rect = img.Rect.Sub(anim.Bounds.Min)
+			img = &shifted
+		}
+		var disposal byte
+		switch frame.Disposal {
+		case DisposalBackground:
+			disposal = gif.DisposalBackground
+		case DisposalPrevious:
+			disposal = gif.DisposalPrevious
+		default:
+			disposal = gif.DisposalNone
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, int((frame.Delay+5*time.Millisecond)/(10*time.Millisecond)))
+		g.Disposal = append(g.Disposal, disposal)
+	}
+	if err := gif.EncodeAll(w, g); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WriteGIFFile writes the animation as an animated GIF file specified by
+// imgPath. The file is written atomically; see WriteFileExt.
+func WriteGIFFile(imgPath string, anim *Animation) error {
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return WriteGIF(w, anim)
+	})
+}
+
+// pngSignature is the file signature of PNG images.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// maxPNGSize is the maximum total size in bytes of the chunks of a PNG image
+// read by ReadAPNG.
+const maxPNGSize = 256 << 20
+
+// pngChunk is a chunk of a PNG image.
+type pngChunk struct {
+	// Chunk type.
+	typ string
+	// Chunk data.
+	data []byte
+}
+
+// readPNGChunks reads the chunks of a PNG image from r.
+func readPNGChunks(r io.Reader) ([]pngChunk, error) {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if string(sig) != pngSignature {
+		return nil, errors.New("invalid PNG signature")
+	}
+	var chunks []pngChunk
+	total := int64(0)
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		n := binary.BigEndian.Uint32(hdr[:4])
+		if n > 0x7FFFFFFF {
+			return nil, errors.Errorf("invalid PNG chunk length %d", n)
+		}
+		total += int64(n) + 4
+		if total > maxPNGSize {
+			return nil, errors.Errorf("PNG image exceeds maximum size of %d bytes", maxPNGSize)
+		}
+		// Grow the buffer as data is read, rather than trusting the declared
+		// length of the chunk.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(n)+4); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, errors.WithStack(err)
+		}
+		data := buf.Bytes()
+		typ := string(hdr[4:])
+		crc := crc32.NewIEEE()
+		crc.Write(hdr[4:])
+		crc.Write(data[:n])
+		if crc.Sum32() != binary.BigEndian.Uint32(data[n:]) {
+			return nil, errors.Errorf("invalid checksum of PNG chunk %q", typ)
+		}
+		chunks = append(chunks, pngChunk{typ: typ, data: data[:n]})
+		if typ == "IEND" {
+			return chunks, nil
+		}
+	}
+}
+
+// writePNGChunk writes a PNG chunk of the given type and data to w.
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	for _, buf := range [][]byte{hdr[:], data, sum[:]} {
+		if _, err := w.Write(buf); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// fcTL is the frame control chunk of an APNG image.
+type fcTL struct {
+	// Sequence number of the chunk.
+	seq uint32
+	// Size of the frame.
+	width, height uint32
+	// Offset of the frame within the canvas.
+	x, y uint32
+	// Frame delay in seconds (numerator / denominator).
+	delayNum, delayDen uint16
+	// Disposal operation (0: none, 1: background, 2: previous).
+	dispose uint8
+	// Blend operation (0: source, 1: over).
+	blend uint8
+}
+
+// ReadAPNG reads all frames of an animated PNG image from r. Static PNG images
+// are returned as a single frame animation.
+func ReadAPNG(r io.Reader) (*Animation, error) {
+	chunks, err := readPNGChunks(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New("invalid PNG header")
+	}
+	ihdr := chunks[0].data
+	anim := &Animation{
+		Bounds: image.Rect(0, 0, int(binary.BigEndian.Uint32(ihdr[0:4])), int(binary.BigEndian.Uint32(ihdr[4:8]))),
+	}
+	// Chunks preceding the image data, shared by all frames.
+	var shared []pngChunk
+	animated := false
+	var ctl *fcTL
+	var data [][]byte
+	seenIDAT := false
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		defer func() { data = nil }()
+		if ctl == nil {
+			// Default image which is not part of the animation.
+			if animated {
+				return nil
+			}
+			ctl = &fcTL{width: uint32(anim.Bounds.Dx()), height: uint32(anim.Bounds.Dy())}
+		}
+		img, err := decodeAPNGFrame(ihdr, shared, ctl, data)
+		if err != nil {
+			return err
+		}
+		den := ctl.delayDen
+		if den == 0 {
+			den = 100
+		}
+		frame := Frame{
+			Image: img,
+			Delay: time.Duration(ctl.delayNum) * time.Second / time.Duration(den),
+		}
+		switch ctl.dispose {
+		case 1:
+			frame.Disposal = DisposalBackground
+		case 2:
+			frame.Disposal = DisposalPrevious
+		}
+		if ctl.blend == 0 {
+			frame.Blend = BlendSource
+		}
+		anim.Frames = append(anim.Frames, frame)
+		ctl = nil
+		return nil
+	}
+	for _, chunk := range chunks[1:] {
+		switch chunk.typ {
+		case "acTL":
+			if len(chunk.data) != 8 {
+				return nil, errors.New("invalid APNG animation control chunk")
+			}
+			animated = true
+			anim.Loops = int(binary.BigEndian.Uint32(chunk.data[4:8]))
+		case "fcTL":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if len(chunk.data) != 26 {
+				return nil, errors.New("invalid APNG frame control chunk")
+			}
+			d := chunk.data
+			ctl = &fcTL{
+				seq:      binary.BigEndian.Uint32(d[0:4]),
+				width:    binary.BigEndian.Uint32(d[4:8]),
+				height:   binary.BigEndian.Uint32(d[8:12]),
+				x:        binary.BigEndian.Uint32(d[12:16]),
+				y:        binary.BigEndian.Uint32(d[16:20]),
+				delayNum: binary.BigEndian.Uint16(d[20:22]),
+				delayDen: binary.BigEndian.Uint16(d[22:24]),
+				dispose:  d[24],
+				blend:    d[25],
+			}
+		case "IDAT":
+			seenIDAT = true
+			data = append(data, chunk.data)
+		case "fdAT":
+			if len(chunk.data) < 4 {
+				return nil, errors.New("invalid APNG frame data chunk")
+			}
+			data = append(data, chunk.data[4:])
+		case "IEND":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			if !seenIDAT {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+	if len(anim.Frames) == 0 {
+		return nil, errors.New("no frames in PNG image")
+	}
+	return anim, nil
+}
+
+// decodeAPNGFrame decodes the frame of an APNG image with the given header,
+// shared chunks, frame control and image data, by constructing an equivalent
+// standalone PNG image.
+func decodeAPNGFrame(ihdr []byte, shared []pngChunk, ctl *fcTL, data [][]byte) (image.Image, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(pngSignature)
+	hdr := bytes.Clone(ihdr)
+	binary.BigEndian.PutUint32(hdr[0:4], ctl.width)
+	binary.BigEndian.PutUint32(hdr[4:8], ctl.height)
+	writePNGChunk(buf, "IHDR", hdr)
+	for _, chunk := range shared {
+		writePNGChunk(buf, chunk.typ, chunk.data)
+	}
+	writePNGChunk(buf, "IDAT", bytes.Join(data, nil))
+	writePNGChunk(buf, "IEND", nil)
+	img, err := png.Decode(buf)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return translate(img, image.Pt(int(ctl.x), int(ctl.y))), nil
+}
+
+// translate returns the image img translated by off. Standard image types are
+// translated in place.
+func translate(img image.Image, off image.Point) image.Image {
+	if off == (image.Point{}) {
+		return img
+	}
+	switch img := img.(type) {
+	case *image.RGBA:
+		img.Rect = img.Rect.Add(off)
+	case *image.NRGBA:
+		img.Rect = img.Rect.Add(off)
+	case *image.RGBA64:
+		img.Rect = img.Rect.Add(off)
+	case *image.NRGBA64:
+		img.Rect = img.Rect.Add(off)
+	case *image.Gray:
+		img.Rect = img.Rect.Add(off)
+	case *image.Gray16:
+		img.Rect = img.Rect.Add(off)
+	case *image.Paletted:
+		img.Rect = img.Rect.Add(off)
+	default:
+		r := img.Bounds()
+		dst := image.NewNRGBA(r.Add(off))
+		draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+		return dst
+	}
+	return img
+}
+
+// ReadAPNGFile reads all frames of an animated PNG file specified by imgPath.
+func ReadAPNGFile(imgPath string) (*Animation, error) {
+	f, err := os.Open(imgPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	anim, err := ReadAPNG(bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode %q", imgPath)
+	}
+	return anim, nil
+}
+
+// WriteAPNG writes the animation as an animated PNG image to w. Frames are
+// stored as 8-bit RGBA, and the first frame is used as the default image.
+func WriteAPNG(w io.Writer, anim *Animation) error {
+	if len(anim.Frames) == 0 {
+		return errors.New("no frames in animation")
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(pngSignature); err != nil {
+		return errors.WithStack(err)
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(anim.Bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(anim.Bounds.Dy()))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // colour type: RGBA
+	if err := writePNGChunk(bw, "IHDR", ihdr); err != nil {
+		return err
+	}
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(anim.Frames)))
+	binary.BigEndian.PutUint32(actl[4:8], uint32(anim.Loops))
+	if err := writePNGChunk(bw, "acTL", actl); err != nil {
+		return err
+	}
+	var seq uint32
+	for i, frame := range anim.Frames {
+		img := frame.Image
+		r := img.Bounds().Intersect(anim.Bounds)
+		if i == 0 && r != anim.Bounds {
+			// The first frame must cover the canvas, as it is the default image.
+			canvas := image.NewNRGBA(anim.Bounds)
+			draw.Draw(canvas, r, img, r.Min, draw.Src)
+			img, r = canvas, anim.Bounds
+		}
+		if r.Empty() {
+			return errors.Errorf("frame %d outside of canvas", i)
+		}
+		ctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(ctl[0:4], seq)
+		binary.BigEndian.PutUint32(ctl[4:8], uint32(r.Dx()))
+		binary.BigEndian.PutUint32(ctl[8:12], uint32(r.Dy()))
+		binary.BigEndian.PutUint32(ctl[12:16], uint32(r.Min.X-anim.Bounds.Min.X))
+		binary.BigEndian.PutUint32(ctl[16:20], uint32(r.Min.Y-anim.Bounds.Min.Y))
+		num, den := apngDelay(frame.Delay)
+		binary.BigEndian.PutUint16(ctl[20:22], num)
+		binary.BigEndian.PutUint16(ctl[22:24], den)
+		switch frame.Disposal {
+		case DisposalBackground:
+			ctl[24] = 1
+		case DisposalPrevious:
+			ctl[24] = 2
+		}
+		if frame.Blend == BlendOver {
+			ctl[25] = 1
+		}
+		if err := writePNGChunk(bw, "fcTL", ctl); err != nil {
+			return err
+		}
+		seq++
+		data, err := encodeRGBA(img, r)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if err := writePNGChunk(bw, "IDAT", data); err != nil {
+				return err
+			}
+			continue
+		}
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat[0:4], seq)
+		copy(fdat[4:], data)
+		if err := writePNGChunk(bw, "fdAT", fdat); err != nil {
+			return err
+		}
+		seq++
+	}
+	if err := writePNGChunk(bw, "IEND", nil); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WriteAPNGFile writes the animation as an animated PNG file specified by
+// imgPath. The file is written atomically; see WriteFileExt.
+func WriteAPNGFile(imgPath string, anim *Animation) error {
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return WriteAPNG(w, anim)
+	})
+}
+
+// apngDelay returns the numerator and denominator of the given frame delay.
+func apngDelay(delay time.Duration) (num, den uint16) {
+	for _, den := range []uint16{1000, 100, 10, 1} {
+		n := (delay*time.Duration(den) + time.Second/2) / time.Second
+		if n <= 0xFFFF {
+			return uint16(n), den
+		}
+	}
+	return 0xFFFF, 1
+}
+
+// encodeRGBA returns the zlib compressed 8-bit RGBA image data of the part r of
+// img, using the PNG filter type None.
+func encodeRGBA(img image.Image, r image.Rectangle) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	row := make([]byte, 1+4*r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := 1 + 4*(x-r.Min.X)
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,113 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestAnimation(t *testing.T) {
+	anim := &imgutil.Animation{
+		Bounds: image.Rect(0, 0, 8, 8),
+		Loops:  3,
+	}
+	colors := []color.NRGBA{
+		{R: 0xFF, A: 0xFF},
+		{G: 0xFF, A: 0xFF},
+		{B: 0xFF, A: 0xFF},
+	}
+	for i, c := range colors {
+		// The first frame covers the canvas, subsequent frames a 4x4 region.
+		r := anim.Bounds
+		if i > 0 {
+			r = image.Rect(2*i, 2*i, 2*i+4, 2*i+4).Intersect(anim.Bounds)
+		}
+		img := image.NewNRGBA(r)
+		for j := 0; j < len(img.Pix); j += 4 {
+			img.Pix[j], img.Pix[j+1], img.Pix[j+2], img.Pix[j+3] = c.R, c.G, c.B, c.A
+		}
+		frame := imgutil.Frame{Image: img, Delay: 50 * time.Millisecond}
+		if i == 1 {
+			frame.Disposal = imgutil.DisposalPrevious
+		}
+		anim.Frames = append(anim.Frames, frame)
+	}
+	want := anim.Composite()
+	if got := want[2].NRGBAAt(3, 3); got != colors[0] {
+		t.Errorf("composite pixel mismatch; expected %v, got %v", colors[0], got)
+	}
+	golden := []struct {
+		name  string
+		write func(buf *bytes.Buffer) error
+		read  func(buf *bytes.Buffer) (*imgutil.Animation, error)
+	}{
+		{
+			name:  "APNG",
+			write: func(buf *bytes.Buffer) error { return imgutil.WriteAPNG(buf, anim) },
+			read:  func(buf *bytes.Buffer) (*imgutil.Animation, error) { return imgutil.ReadAPNG(buf) },
+		},
+		{
+			name:  "GIF",
+			write: func(buf *bytes.Buffer) error { return imgutil.WriteGIF(buf, anim) },
+			read:  func(buf *bytes.Buffer) (*imgutil.Animation, error) { return imgutil.ReadGIF(buf) },
+		},
+	}
+	for _, g := range golden {
+		buf := &bytes.Buffer{}
+		if err := g.write(buf); err != nil {
+			t.Errorf("%s: unable to write animation; %v", g.name, err)
+			continue
+		}
+		got, err := g.read(buf)
+		if err != nil {
+			t.Errorf("%s: unable to read animation; %v", g.name, err)
+			continue
+		}
+		if len(got.Frames) != len(anim.Frames) || got.Loops != anim.Loops || got.Bounds != anim.Bounds {
+			t.Errorf("%s: animation mismatch; expected %d frames, %d loops and bounds %v, got %d frames, %d loops and bounds %v", g.name, len(anim.Frames), anim.Loops, anim.Bounds, len(got.Frames), got.Loops, got.Bounds)
+			continue
+		}
+		for i, img := range got.Composite() {
+			if got.Frames[i].Delay != anim.Frames[i].Delay {
+				t.Errorf("%s: frame %d delay mismatch; expected %v, got %v", g.name, i, anim.Frames[i].Delay, got.Frames[i].Delay)
+			}
+			if !imgutil.Equal(want[i], img) {
+				t.Errorf("%s: frame %d mismatch", g.name, i)
+			}
+		}
+	}
+}
+
+func TestReadAPNGTruncated(t *testing.T) {
+	golden := []struct {
+		// Declared length of the IHDR chunk.
+		n    uint32
+		want error
+	}{
+		// Within the size limit; fails once the data runs out.
+		{n: 100 << 20, want: io.ErrUnexpectedEOF},
+		// Exceeds the size limit; fails before reading the data.
+		{n: 0x7FFFFFF0},
+	}
+	for _, g := range golden {
+		buf := &bytes.Buffer{}
+		buf.WriteString("\x89PNG\r\n\x1a\n")
+		binary.Write(buf, binary.BigEndian, g.n)
+		buf.WriteString("IHDR\x00\x00\x00\x08")
+		_, err := imgutil.ReadAPNG(buf)
+		if err == nil {
+			t.Errorf("chunk length %d: expected error for truncated PNG image", g.n)
+			continue
+		}
+		if g.want != nil && !errors.Is(err, g.want) {
+			t.Errorf("chunk length %d: error mismatch; expected %v, got %v", g.n, g.want, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package imgutil
+
+import (
+	"io"
+
+	"github.com/mewkiz/pkg/osutil"
+)
+
+// writeFileAtomic atomically writes the file specified by path, using the given
+// write function to produce its contents. The file is created using mode 0666
+// (before umask) to match the permissions of os.Create. See
+// osutil.WriteFileAtomic for details.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	return osutil.WriteFileAtomic(path, 0666, write)
+}
@@ -0,0 +1,231 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// CompareOptions specifies the options of image comparison.
+type CompareOptions struct {
+	// Maximum per-channel difference (in 8-bit units) for pixels to be
+	// considered equal.
+	Tolerance uint8
+	// Pixels for which Ignore has a non-zero alpha value are ignored; may be
+	// nil.
+	Ignore image.Image
+	// Produce a visual diff image highlighting changed pixels.
+	DiffImage bool
+}
+
+// CompareResult is the result of image comparison.
+type CompareResult struct {
+	// Number of pixels differing by more than the tolerance in any channel.
+	DiffCount int
+	// Maximum per-channel difference (in 8-bit units) of any pixel.
+	MaxDelta uint8
+	// Peak signal-to-noise ratio in decibels of the compared (not ignored)
+	// pixels; +Inf for identical images.
+	PSNR float64
+	// Mean structural similarity index of luminance in [-1, 1]; 1 for
+	// identical images.
+	SSIM float64
+	// Visual diff with changed pixels highlighted in red over a faded grayscale
+	// version of the first image; nil unless requested.
+	Diff *image.NRGBA
+}
+
+// Compare compares the images img1 and img2, which must have the same bounds.
+// Pixels are compared as 8-bit alpha-premultiplied RGBA values. If opts is nil,
+// the images are compared with zero tolerance.
+func Compare(img1, img2 image.Image, opts *CompareOptions) (*CompareResult, error) {
+	if opts == nil {
+		opts = &CompareOptions{}
+	}
+	bounds := img1.Bounds()
+	if bounds != img2.Bounds() {
+		return nil, errors.Errorf("image bounds mismatch; %v != %v", bounds, img2.Bounds())
+	}
+	if opts.Ignore != nil && !bounds.In(opts.Ignore.Bounds()) {
+		return nil, errors.Errorf("ignore mask bounds %v do not cover image bounds %v", opts.Ignore.Bounds(), bounds)
+	}
+	res := &CompareResult{}
+	if opts.DiffImage {
+		res.Diff = image.NewNRGBA(bounds)
+	}
+	w, h := bounds.Dx(), bounds.Dy()
+	row1 := make([]uint8, 4*w)
+	row2 := make([]uint8, 4*w)
+	var mask []uint8
+	if opts.Ignore != nil {
+		mask = make([]uint8, 4*w)
+	}
+	luma1 := make([]float64, w*h)
+	luma2 := make([]float64, w*h)
+	read1, read2 := rowReader(img1, bounds), rowReader(img2, bounds)
+	var readMask func(y int, dst []uint8)
+	if opts.Ignore != nil {
+		readMask = rowReader(opts.Ignore, bounds)
+	}
+	var sumSq float64
+	// Number of compared pixels, excluding ignored ones.
+	var n int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		read1(y, row1)
+		read2(y, row2)
+		if readMask != nil {
+			readMask(y, mask)
+		}
+		j := y - bounds.Min.Y
+		for i := 0; i < w; i++ {
+			p1, p2 := row1[4*i:4*i+4], row2[4*i:4*i+4]
+			l1 := luma(p1)
+			luma1[j*w+i] = l1
+			if mask != nil && mask[4*i+3] != 0 {
+				// Ignored pixel.
+				luma2[j*w+i] = l1
+				if res.Diff != nil {
+					setFaded(res.Diff, bounds.Min.X+i, y, l1)
+				}
+				continue
+			}
+			luma2[j*w+i] = luma(p2)
+			n++
+			changed := false
+			for c := 0; c < 4; c++ {
+				d := absDiff(p1[c], p2[c])
+				sumSq += float64(d) * float64(d)
+				if d > res.MaxDelta {
+					res.MaxDelta = d
+				}
+				if d > opts.Tolerance {
+					changed = true
+				}
+			}
+			if changed {
+				res.DiffCount++
+			}
+			if res.Diff != nil {
+				if changed {
+					res.Diff.SetNRGBA(bounds.Min.X+i, y, color.NRGBA{R: 0xFF, A: 0xFF})
+				} else {
+					setFaded(res.Diff, bounds.Min.X+i, y, l1)
+				}
+			}
+		}
+	}
+	res.PSNR = math.Inf(1)
+	if sumSq > 0 {
+		mse := sumSq / float64(4*n)
+		res.PSNR = 10 * math.Log10(255*255/mse)
+	}
+	res.SSIM = ssim(luma1, luma2, w, h)
+	return res, nil
+}
+
+// ssimWindow is the window size of SSIM computation.
+const ssimWindow = 8
+
+// ssim returns the mean structural similarity index of the given luminance
+// planes of size w x h, computed over non-overlapping windows.
+func ssim(luma1, luma2 []float64, w, h int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+	var total float64
+	var n int
+	for y0 := 0; y0 < h; y0 += ssimWindow {
+		for x0 := 0; x0 < w; x0 += ssimWindow {
+			y1, x1 := min(y0+ssimWindow, h), min(x0+ssimWindow, w)
+			count := float64((y1 - y0) * (x1 - x0))
+			var sum1, sum2 float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum1 += luma1[y*w+x]
+					sum2 += luma2[y*w+x]
+				}
+			}
+			mu1, mu2 := sum1/count, sum2/count
+			var var1, var2, cov float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					d1 := luma1[y*w+x] - mu1
+					d2 := luma2[y*w+x] - mu2
+					var1 += d1 * d1
+					var2 += d2 * d2
+					cov += d1 * d2
+				}
+			}
+			var1 /= count
+			var2 /= count
+			cov /= count
+			total += ((2*mu1*mu2 + c1) * (2*cov + c2)) / ((mu1*mu1 + mu2*mu2 + c1) * (var1 + var2 + c2))
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return total / float64(n)
+}
+
+// rowReader returns a function which reads the pixels of row y of img within
+// the horizontal extent of bounds as 8-bit alpha-premultiplied RGBA values into
+// dst. Fast paths avoid the generic At method for standard image types.
+func rowReader(img image.Image, bounds image.Rectangle) func(y int, dst []uint8) {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return func(y int, dst []uint8) {
+			i := img.PixOffset(bounds.Min.X, y)
+			copy(dst, img.Pix[i:i+4*bounds.Dx()])
+		}
+	case *image.NRGBA:
+		return func(y int, dst []uint8) {
+			i := img.PixOffset(bounds.Min.X, y)
+			src := img.Pix[i : i+4*bounds.Dx()]
+			for j := 0; j < len(src); j += 4 {
+				r, g, b, a := color.NRGBA{src[j], src[j+1], src[j+2], src[j+3]}.RGBA()
+				dst[j], dst[j+1], dst[j+2], dst[j+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+			}
+		}
+	case *image.Gray:
+		return func(y int, dst []uint8) {
+			i := img.PixOffset(bounds.Min.X, y)
+			for j, v := range img.Pix[i : i+bounds.Dx()] {
+				dst[4*j], dst[4*j+1], dst[4*j+2], dst[4*j+3] = v, v, v, 0xFF
+			}
+		}
+	default:
+		return func(y int, dst []uint8) {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := img.At(x, y).RGBA()
+				j := 4 * (x - bounds.Min.X)
+				dst[j], dst[j+1], dst[j+2], dst[j+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+			}
+		}
+	}
+}
+
+// luma returns the luminance of the given 8-bit RGBA pixel, using the Rec. 601
+// coefficients.
+func luma(p []uint8) float64 {
+	return 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+}
+
+// setFaded sets the pixel at (x, y) of the diff image to a faded grayscale
+// representation of the given luminance.
+func setFaded(diff *image.NRGBA, x, y int, l float64) {
+	v := uint8(0xC0 + l/4)
+	diff.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xFF})
+}
+
+// absDiff returns the absolute difference between a and b.
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
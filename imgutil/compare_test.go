@@ -0,0 +1,62 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestCompare(t *testing.T) {
+	r := image.Rect(0, 0, 16, 16)
+	img1 := image.NewNRGBA(r)
+	img2 := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBA{R: uint8(16 * x), G: uint8(16 * y), B: 0x80, A: 0xFF}
+			img1.SetNRGBA(x, y, c)
+			img2.Set(x, y, c)
+		}
+	}
+	// Identical images.
+	res, err := imgutil.Compare(img1, img2, nil)
+	if err != nil {
+		t.Fatalf("unable to compare images; %v", err)
+	}
+	if res.DiffCount != 0 || res.MaxDelta != 0 || !math.IsInf(res.PSNR, 1) || res.SSIM != 1 {
+		t.Errorf("expected identical images, got %+v", res)
+	}
+	if !imgutil.Equal(img1, img2) {
+		t.Errorf("expected images to be equal")
+	}
+	// Small and large differences.
+	img2.Set(1, 1, color.RGBA{R: 16 + 2, G: 16, B: 0x80, A: 0xFF})
+	img2.Set(5, 5, color.RGBA{A: 0xFF})
+	mask := image.NewAlpha(r)
+	res, err = imgutil.Compare(img1, img2, &imgutil.CompareOptions{Tolerance: 2, DiffImage: true})
+	if err != nil {
+		t.Fatalf("unable to compare images; %v", err)
+	}
+	if res.DiffCount != 1 || res.MaxDelta != 0x80 || res.SSIM >= 1 {
+		t.Errorf("difference mismatch; expected 1 changed pixel with max delta 0x80, got %+v", res)
+	}
+	if got, want := res.Diff.NRGBAAt(5, 5), (color.NRGBA{R: 0xFF, A: 0xFF}); got != want {
+		t.Errorf("diff image pixel mismatch; expected %v, got %v", want, got)
+	}
+	// Ignore mask.
+	mask.SetAlpha(5, 5, color.Alpha{A: 0xFF})
+	res, err = imgutil.Compare(img1, img2, &imgutil.CompareOptions{Tolerance: 2, Ignore: mask})
+	if err != nil {
+		t.Fatalf("unable to compare images; %v", err)
+	}
+	if res.DiffCount != 0 || res.MaxDelta != 2 {
+		t.Errorf("difference mismatch; expected no changed pixels with max delta 2, got %+v", res)
+	}
+	// The PSNR is computed over the 255 compared pixels only.
+	mse := 2.0 * 2.0 / (4 * 255)
+	if want := 10 * math.Log10(255*255/mse); math.Abs(res.PSNR-want) > 1e-9 {
+		t.Errorf("PSNR mismatch; expected %v, got %v", want, res.PSNR)
+	}
+}
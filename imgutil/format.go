@@ -0,0 +1,116 @@
+package imgutil
+
+import (
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// WriteOptions specifies the options of image encoding.
+type WriteOptions struct {
+	// Image format ("png", "jpeg", "gif", "bmp" or "tiff"). If empty, the
+	// format is determined by the file extension.
+	Format string
+	// Quality of JPEG images within the range [1, 100]; higher is better. If 0,
+	// jpeg.DefaultQuality is used.
+	JPEGQuality int
+	// Compression level of PNG images.
+	PNGCompression png.CompressionLevel
+	// Options of GIF images; may be nil.
+	GIF *gif.Options
+	// Options of TIFF images; may be nil.
+	TIFF *tiff.Options
+}
+
+// FormatFromPath returns the name of the image format (e.g. "jpeg") associated
+// with the file extension of imgPath.
+func FormatFromPath(imgPath string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(imgPath))
+	switch ext {
+	case ".png":
+		return "png", nil
+	case ".jpg", ".jpeg":
+		return "jpeg", nil
+	case ".gif":
+		return "gif", nil
+	case ".bmp":
+		return "bmp", nil
+	case ".tif", ".tiff":
+		return "tiff", nil
+	}
+	return "", errors.Errorf("unable to determine image format of %q; unsupported file extension %q", imgPath, ext)
+}
+
+// WriteFileExt writes the image data to a file specified by imgPath, using the
+// encoder of the image format determined by opts.Format or the file extension
+// of imgPath (png, jpg, gif, bmp or tiff). WriteFileExt creates the named file
+// using mode 0666 (before umask), replacing it if it already exists. If opts is
+// nil, default options are used.
+//
+// The file is written atomically; the image data is written to a temporary file
+// in the same directory, which is synced to disk and renamed to imgPath once
+// complete. Thus, a failed write never leaves a truncated image behind.
+func WriteFileExt(imgPath string, img image.Image, opts *WriteOptions) (err error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	format := opts.Format
+	if len(format) == 0 {
+		format, err = FormatFromPath(imgPath)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return encode(w, img, format, opts)
+	})
+}
+
+// Encode writes the image data to w, using the encoder of the image format
+// specified by opts.Format, or PNG if opts is nil or opts.Format is empty.
+func Encode(w io.Writer, img image.Image, opts *WriteOptions) error {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	format := opts.Format
+	if len(format) == 0 {
+		format = "png"
+	}
+	return encode(w, img, format, opts)
+}
+
+// encode writes the image data to w in the given image format.
+func encode(w io.Writer, img image.Image, format string, opts *WriteOptions) error {
+	var err error
+	switch format {
+	case "png":
+		enc := &png.Encoder{CompressionLevel: opts.PNGCompression}
+		err = enc.Encode(w, img)
+	case "jpeg", "jpg":
+		quality := opts.JPEGQuality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		err = gif.Encode(w, img, opts.GIF)
+	case "bmp":
+		err = bmp.Encode(w, img)
+	case "tiff":
+		err = tiff.Encode(w, img, opts.TIFF)
+	default:
+		return errors.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
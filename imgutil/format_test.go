@@ -0,0 +1,108 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestWriteFileExt(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	img.SetNRGBA(1, 2, color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xFF})
+	dir := t.TempDir()
+	golden := []struct {
+		name   string
+		format string
+		lossy  bool
+	}{
+		{name: "a.png", format: "png"},
+		{name: "b.BMP", format: "bmp"},
+		{name: "c.tiff", format: "tiff"},
+		{name: "d.jpg", format: "jpeg", lossy: true},
+		{name: "e.Gif", format: "gif", lossy: true},
+		{name: "f.PNG", format: "png"},
+	}
+	for _, g := range golden {
+		imgPath := filepath.Join(dir, g.name)
+		if err := imgutil.WriteFileExt(imgPath, img, nil); err != nil {
+			t.Errorf("unable to write %q; %v", g.name, err)
+			continue
+		}
+		got, format, err := imgutil.ReadFileFormat(imgPath)
+		if err != nil {
+			t.Errorf("unable to read %q; %v", g.name, err)
+			continue
+		}
+		if format != g.format {
+			t.Errorf("%q: format mismatch; expected %q, got %q", g.name, g.format, format)
+		}
+		if !g.lossy && !imgutil.Equal(img, got) {
+			t.Errorf("%q: image mismatch after round trip", g.name)
+		}
+	}
+	// Only the written images should remain; no temporary files.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(golden) {
+		t.Errorf("directory entry count mismatch; expected %d, got %d", len(golden), len(entries))
+	}
+	for _, name := range []string{"g.xyz", "h"} {
+		if err := imgutil.WriteFileExt(filepath.Join(dir, name), img, nil); err == nil {
+			t.Errorf("%q: expected error for unsupported file extension", name)
+		}
+	}
+	// An explicit format overrides the file extension.
+	imgPath := filepath.Join(dir, "i.xyz")
+	if err := imgutil.WriteFileExt(imgPath, img, &imgutil.WriteOptions{Format: "bmp"}); err != nil {
+		t.Fatalf("unable to write %q; %v", imgPath, err)
+	}
+	if _, format, err := imgutil.ReadFileFormat(imgPath); err != nil || format != "bmp" {
+		t.Errorf("%q: format mismatch; expected %q, got %q (%v)", imgPath, "bmp", format, err)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	golden := []struct {
+		path   string
+		format string
+		err    bool
+	}{
+		{path: "a.png", format: "png"},
+		{path: "dir/b.PNG", format: "png"},
+		{path: "c.jpg", format: "jpeg"},
+		{path: "d.JPEG", format: "jpeg"},
+		{path: "e.gif", format: "gif"},
+		{path: "f.Bmp", format: "bmp"},
+		{path: "g.tif", format: "tiff"},
+		{path: "h.TIFF", format: "tiff"},
+		{path: "i.webp", err: true},
+		{path: "j.txt", err: true},
+		{path: "k", err: true},
+		{path: "png", err: true},
+	}
+	for _, g := range golden {
+		format, err := imgutil.FormatFromPath(g.path)
+		if g.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got format %q", g.path, format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", g.path, err)
+			continue
+		}
+		if format != g.format {
+			t.Errorf("%q: format mismatch; expected %q, got %q", g.path, g.format, format)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package imgutil
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/mewkiz/pkg/natsort"
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+)
+
+// A Hash is a 64-bit perceptual image hash. Similar images have hashes with a
+// small Hamming distance.
+type Hash uint64
+
+// String returns the hash as 16 hexadecimal digits.
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// ParseHash parses the given hexadecimal image hash, as returned by
+// Hash.String.
+func ParseHash(s string) (Hash, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return Hash(v), nil
+}
+
+// Distance returns the Hamming distance between the image hashes h1 and h2;
+// i.e. the number of differing bits.
+func Distance(h1, h2 Hash) int {
+	return bits.OnesCount64(uint64(h1 ^ h2))
+}
+
+// A HashFunc computes the hash of an image.
+type HashFunc func(img image.Image) Hash
+
+// AverageHash returns the average hash (aHash) of the image img. The image is
+// scaled down to 8x8 grayscale pixels, and each bit of the hash is set if the
+// corresponding pixel is brighter than the mean.
+func AverageHash(img image.Image) Hash {
+	pix := grayPixels(img, 8, 8)
+	var sum float64
+	for _, v := range pix {
+		sum += v
+	}
+	mean := sum / float64(len(pix))
+	var h Hash
+	for i, v := range pix {
+		if v > mean {
+			h |= 1 << (63 - i)
+		}
+	}
+	return h
+}
+
+// DifferenceHash returns the difference hash (dHash) of the image img. The
+// image is scaled down to 9x8 grayscale pixels, and each bit of the hash is set
+// if the corresponding pixel is brighter than its right neighbour.
+func DifferenceHash(img image.Image) Hash {
+	const w, h = 9, 8
+	pix := grayPixels(img, w, h)
+	var hash Hash
+	i := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			if pix[y*w+x] > pix[y*w+x+1] {
+				hash |= 1 << (63 - i)
+			}
+			i++
+		}
+	}
+	return hash
+}
+
+// PerceptualHash returns the perceptual hash (pHash) of the image img. The
+// image is scaled down to 32x32 grayscale pixels, and each bit of the hash is
+// set if the corresponding coefficient of the lowest 8x8 frequencies of its
+// discrete cosine transform is greater than their median. The DC term, which
+// only reflects the mean brightness, is excluded from the median and its bit
+// (the most significant) is always zero.
+func PerceptualHash(img image.Image) Hash {
+	const n, m = 32, 8
+	pix := grayPixels(img, n, n)
+	// Cosine basis functions of the lowest m frequencies.
+	var cos [m][n]float64
+	for u := range m {
+		for x := range n {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	// Separable 2D DCT-II; rows first, then columns.
+	var rows [n][m]float64
+	for y := range n {
+		for u := range m {
+			var sum float64
+			for x := range n {
+				sum += pix[y*n+x] * cos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefs := make([]float64, 0, m*m)
+	for v := range m {
+		for u := range m {
+			var sum float64
+			for y := range n {
+				sum += rows[y][u] * cos[v][y]
+			}
+			coefs = append(coefs, sum)
+		}
+	}
+	sorted := slices.Clone(coefs[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	var h Hash
+	for i, c := range coefs[1:] {
+		if c > median {
+			h |= 1 << (62 - i)
+		}
+	}
+	return h
+}
+
+// grayPixels returns the luminance of the pixels of the image img scaled down
+// to w x h pixels, in row-major order.
+func grayPixels(img image.Image, w, h int) []float64 {
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	xdraw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	pix := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pix[y*w+x] = float64(dst.Pix[dst.PixOffset(x, y)])
+		}
+	}
+	return pix
+}
+
+// GroupHashes groups the given image hashes into clusters of near-duplicates,
+// where two hashes belong to the same cluster if they are connected through a
+// chain of hashes at most maxDist bits apart. Each cluster holds the indices of
+// its hashes in increasing order, and clusters are ordered by their first
+// index. Hashes without near-duplicates are omitted.
+func GroupHashes(hashes []Hash, maxDist int) [][]int {
+	// Union-find of hash indices.
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if Distance(hashes[i], hashes[j]) > maxDist {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			if ri != rj {
+				// Keep the lowest index as root to preserve order.
+				parent[max(ri, rj)] = min(ri, rj)
+			}
+		}
+	}
+	clusters := make(map[int][]int)
+	var roots []int
+	for i := range hashes {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], i)
+	}
+	var groups [][]int
+	for _, root := range roots {
+		if len(clusters[root]) > 1 {
+			groups = append(groups, clusters[root])
+		}
+	}
+	return groups
+}
+
+// GroupDir groups the images of the directory dir into clusters of
+// near-duplicates, using the given hash function (e.g. PerceptualHash) and
+// maximum Hamming distance. Files are visited in natural sort order, and files
+// which are not images of a supported format or fail to decode (e.g. truncated
+// images) are skipped. Each cluster holds the paths of its images in natural
+// sort order. Images without near-duplicates are omitted.
+func GroupDir(dir string, hash HashFunc, maxDist int) ([][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	natsort.Strings(names)
+	var paths []string
+	var hashes []Hash
+	for _, name := range names {
+		imgPath := filepath.Join(dir, name)
+		img, err := ReadFile(imgPath)
+		if err != nil {
+			// Skip files which fail to decode, but not files which fail to
+			// read.
+			var pathErr *fs.PathError
+			if !errors.As(err, &pathErr) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "unable to read image %q", imgPath)
+		}
+		paths = append(paths, imgPath)
+		hashes = append(hashes, hash(img))
+	}
+	var groups [][]string
+	for _, cluster := range GroupHashes(hashes, maxDist) {
+		group := make([]string, len(cluster))
+		for i, j := range cluster {
+			group[i] = paths[j]
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
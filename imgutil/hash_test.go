@@ -0,0 +1,101 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+// pattern returns a w x h image of a smooth pattern, evaluated in coordinates
+// relative to the image size so that it looks alike at any scale. The pattern
+// is mirrored if flip is set.
+func pattern(w, h int, flip bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := (float64(x)+0.5)/float64(w), (float64(y)+0.5)/float64(h)
+			if flip {
+				fx = 1 - fx
+			}
+			v := math.Sin(5*fx*fy+2*fx) * math.Cos(3*fy-fx*fx)
+			img.SetGray(x, y, color.Gray{Y: uint8(127.5 + 127.5*v)})
+		}
+	}
+	return img
+}
+
+func TestHash(t *testing.T) {
+	funcs := []struct {
+		name string
+		hash imgutil.HashFunc
+	}{
+		{name: "aHash", hash: imgutil.AverageHash},
+		{name: "dHash", hash: imgutil.DifferenceHash},
+		{name: "pHash", hash: imgutil.PerceptualHash},
+	}
+	for _, f := range funcs {
+		orig := f.hash(pattern(64, 48, false))
+		scaled := f.hash(pattern(128, 96, false))
+		other := f.hash(pattern(64, 48, true))
+		if d := imgutil.Distance(orig, scaled); d > 4 {
+			t.Errorf("%s: distance of scaled image too large; expected <= 4, got %d", f.name, d)
+		}
+		if f.name == "pHash" && orig>>63 != 0 {
+			t.Errorf("%s: DC term bit set of %v", f.name, orig)
+		}
+		if d := imgutil.Distance(orig, other); d < 10 {
+			t.Errorf("%s: distance of different image too small; expected >= 10, got %d", f.name, d)
+		}
+		h, err := imgutil.ParseHash(orig.String())
+		if err != nil {
+			t.Errorf("%s: unable to parse hash; %v", f.name, err)
+		}
+		if h != orig {
+			t.Errorf("%s: hash mismatch after round trip; expected %v, got %v", f.name, orig, h)
+		}
+	}
+}
+
+func TestGroupDir(t *testing.T) {
+	dir := t.TempDir()
+	imgs := map[string]image.Image{
+		"img2.png":  pattern(64, 48, false),
+		"img10.png": pattern(32, 24, false),
+		"img3.png":  pattern(64, 48, true),
+		"img1.png":  pattern(128, 96, false),
+	}
+	for name, img := range imgs {
+		if err := imgutil.WriteFile(filepath.Join(dir, name), img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Truncated image.
+	buf, err := os.ReadFile(filepath.Join(dir, "img1.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img4.png"), buf[:len(buf)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := imgutil.GroupDir(dir, imgutil.PerceptualHash, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{
+		filepath.Join(dir, "img1.png"),
+		filepath.Join(dir, "img2.png"),
+		filepath.Join(dir, "img10.png"),
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups mismatch; expected %v, got %v", want, got)
+	}
+}
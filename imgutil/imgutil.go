@@ -2,74 +2,95 @@
 package imgutil
 
 import (
+	"bytes"
 	"image"
 	"image/color"
 	_ "image/gif" // support for decoding gif images.
 	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 
+	"github.com/pkg/errors"
 	"golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff" // support for decoding tiff images.
+	_ "golang.org/x/image/webp" // support for decoding webp images.
 )
 
-// ReadFile reads an image file (gif, jpeg or png) specified by imgPath and
-// returns it as an image.Image.
+// ReadFile reads an image file (bmp, gif, jpeg, png, tiff or webp) specified by
+// imgPath and returns it as an image.Image.
 func ReadFile(imgPath string) (img image.Image, err error) {
+	img, _, err = ReadFileFormat(imgPath)
+	return img, err
+}
+
+// ReadFileFormat reads an image file (bmp, gif, jpeg, png, tiff or webp)
+// specified by imgPath and returns it as an image.Image, along with the name of
+// the detected image format (e.g. "png").
+func ReadFileFormat(imgPath string) (img image.Image, format string, err error) {
 	fr, err := os.Open(imgPath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer fr.Close()
-	img, _, err = image.Decode(fr)
+	img, format, err = image.Decode(fr)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return img, nil
+	return img, format, nil
 }
 
 // WriteFile writes the image data to a PNG file specified by imgPath. WriteFile
-// creates the named file using mode 0666 (before umask), truncating it if it
-// already exists.
+// creates the named file using mode 0666 (before umask), replacing it if it
+// already exists. The file is written atomically; see WriteFileExt.
 func WriteFile(imgPath string, img image.Image) (err error) {
-	fw, err := os.Create(imgPath)
-	if err != nil {
-		return err
-	}
-	defer fw.Close()
-	if err := png.Encode(fw, img); err != nil {
-		return err
-	}
-	return nil
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return EncodePNG(w, img)
+	})
 }
 
 // WriteJPEG writes the image data to a JPEG file specified by imgPath.
-// writeJPEG creates the named file using mode 0666 (before umask), truncating
-// it if it already exists. The quality of the output image is within the range
-// [1, 100]; higher is better.
+// WriteJPEG creates the named file using mode 0666 (before umask), replacing it
+// if it already exists. The quality of the output image is within the range
+// [1, 100]; higher is better. The file is written atomically; see
+// WriteFileExt.
 func WriteJPEG(imgPath string, img image.Image, quality int) (err error) {
-	fw, err := os.Create(imgPath)
-	if err != nil {
-		return err
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return EncodeJPEG(w, img, quality)
+	})
+}
+
+// WriteBMP writes the image data to a BMP file specified by imgPath.
+// WriteBMP creates the named file using mode 0666 (before umask), replacing it
+// if it already exists. The file is written atomically; see WriteFileExt.
+func WriteBMP(imgPath string, img image.Image) (err error) {
+	return writeFileAtomic(imgPath, func(w io.Writer) error {
+		return EncodeBMP(w, img)
+	})
+}
+
+// EncodePNG writes the image data to w in PNG format.
+func EncodePNG(w io.Writer, img image.Image) error {
+	if err := png.Encode(w, img); err != nil {
+		return errors.WithStack(err)
 	}
-	defer fw.Close()
+	return nil
+}
+
+// EncodeJPEG writes the image data to w in JPEG format. The quality of the
+// output image is within the range [1, 100]; higher is better.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
 	options := &jpeg.Options{Quality: quality}
-	if err := jpeg.Encode(fw, img, options); err != nil {
-		return err
+	if err := jpeg.Encode(w, img, options); err != nil {
+		return errors.WithStack(err)
 	}
 	return nil
 }
 
-// WriteBMP writes the image data to a BMP file specified by imgPath.
-// WriteBMP creates the named file using mode 0666 (before umask), truncating
-// it if it already exists
-func WriteBMP(imgPath string, img image.Image) (err error) {
-	fw, err := os.Create(imgPath)
-	if err != nil {
-		return err
-	}
-	defer fw.Close()
-	if err := bmp.Encode(fw, img); err != nil {
-		return err
+// EncodeBMP writes the image data to w in BMP format.
+func EncodeBMP(w io.Writer, img image.Image) error {
+	if err := bmp.Encode(w, img); err != nil {
+		return errors.WithStack(err)
 	}
 	return nil
 }
@@ -85,9 +106,20 @@ func Equal(img1, img2 image.Image) bool {
 		return false
 	}
 
+	// Fast path for images of the same standard type.
+	if pix1, stride1, pix2, stride2, bpp, ok := rawPix(img1, img2); ok {
+		n := bpp * rect1.Dx()
+		for y := 0; y < rect1.Dy(); y++ {
+			if !bytes.Equal(pix1[y*stride1:y*stride1+n], pix2[y*stride2:y*stride2+n]) {
+				return false
+			}
+		}
+		return true
+	}
+
 	// Compare pixel colors.
-	for x := rect1.Min.X; x < rect1.Max.X; x++ {
-		for y := rect1.Min.Y; y < rect1.Max.Y; y++ {
+	for y := rect1.Min.Y; y < rect1.Max.Y; y++ {
+		for x := rect1.Min.X; x < rect1.Max.X; x++ {
 			c1 := img1.At(x, y)
 			c2 := img2.At(x, y)
 			if !ColorEq(c1, c2) {
@@ -99,6 +131,28 @@ func Equal(img1, img2 image.Image) bool {
 	return true
 }
 
+// rawPix returns the pixel data of the images img1 and img2, starting at their
+// minimum bounds, if both images are of the same standard type. The bpp result
+// specifies the number of bytes per pixel.
+func rawPix(img1, img2 image.Image) (pix1 []byte, stride1 int, pix2 []byte, stride2 int, bpp int, ok bool) {
+	min := img1.Bounds().Min
+	switch img1 := img1.(type) {
+	case *image.RGBA:
+		if img2, ok := img2.(*image.RGBA); ok {
+			return img1.Pix[img1.PixOffset(min.X, min.Y):], img1.Stride, img2.Pix[img2.PixOffset(min.X, min.Y):], img2.Stride, 4, true
+		}
+	case *image.NRGBA:
+		if img2, ok := img2.(*image.NRGBA); ok {
+			return img1.Pix[img1.PixOffset(min.X, min.Y):], img1.Stride, img2.Pix[img2.PixOffset(min.X, min.Y):], img2.Stride, 4, true
+		}
+	case *image.Gray:
+		if img2, ok := img2.(*image.Gray); ok {
+			return img1.Pix[img1.PixOffset(min.X, min.Y):], img1.Stride, img2.Pix[img2.PixOffset(min.X, min.Y):], img2.Stride, 1, true
+		}
+	}
+	return nil, 0, nil, 0, 0, false
+}
+
 // ColorEq returns true if the colors c1 and c2 are equal, and false otherwise.
 func ColorEq(c1, c2 color.Color) bool {
 	r1, g1, b1, a1 := c1.RGBA()
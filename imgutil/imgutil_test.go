@@ -0,0 +1,92 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestEncode(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	img.SetNRGBA(1, 2, color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xFF})
+	golden := []struct {
+		name   string
+		encode func(w io.Writer) error
+		format string
+		lossy  bool
+	}{
+		{name: "EncodePNG", encode: func(w io.Writer) error { return imgutil.EncodePNG(w, img) }, format: "png"},
+		{name: "EncodeJPEG", encode: func(w io.Writer) error { return imgutil.EncodeJPEG(w, img, 90) }, format: "jpeg", lossy: true},
+		{name: "EncodeBMP", encode: func(w io.Writer) error { return imgutil.EncodeBMP(w, img) }, format: "bmp"},
+		{name: "Encode", encode: func(w io.Writer) error { return imgutil.Encode(w, img, nil) }, format: "png"},
+		{name: "Encode tiff", encode: func(w io.Writer) error { return imgutil.Encode(w, img, &imgutil.WriteOptions{Format: "tiff"}) }, format: "tiff"},
+	}
+	for _, g := range golden {
+		var buf bytes.Buffer
+		if err := g.encode(&buf); err != nil {
+			t.Errorf("%s: unable to encode image; %v", g.name, err)
+			continue
+		}
+		got, format, err := image.Decode(&buf)
+		if err != nil {
+			t.Errorf("%s: unable to decode image; %v", g.name, err)
+			continue
+		}
+		if format != g.format {
+			t.Errorf("%s: format mismatch; expected %q, got %q", g.name, g.format, format)
+		}
+		if got.Bounds() != img.Bounds() {
+			t.Errorf("%s: bounds mismatch; expected %v, got %v", g.name, img.Bounds(), got.Bounds())
+		}
+		if !g.lossy && !imgutil.Equal(img, got) {
+			t.Errorf("%s: image mismatch after round trip", g.name)
+		}
+	}
+}
+
+func TestWriteFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	imgPath := filepath.Join(dir, "a.png")
+	if err := imgutil.WriteFile(imgPath, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(imgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed encoding leaves the existing file untouched, and no temporary
+	// file behind.
+	empty := image.NewGray(image.Rectangle{})
+	if err := imgutil.WriteFile(imgPath, empty); err == nil {
+		t.Errorf("expected error for empty image")
+	}
+	got, err := os.ReadFile(imgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("contents mismatch after failed write; expected %d bytes, got %d bytes", len(want), len(got))
+	}
+
+	// A missing directory fails without creating any file.
+	if err := imgutil.WriteFile(filepath.Join(dir, "missing", "b.png"), empty); err == nil {
+		t.Errorf("expected error for missing directory")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory entry count mismatch; expected 1, got %d", len(entries))
+	}
+}
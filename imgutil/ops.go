@@ -0,0 +1,133 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/mewkiz/pkg/geometry"
+)
+
+// Crop returns the portion of the image img within r, with the floating-point
+// coordinates of r rounded outwards to whole pixels. The returned image shares
+// pixels with img.
+func Crop(img image.Image, r geometry.Rectangle) image.Image {
+	ir := r.Image(geometry.RoundOut).Intersect(img.Bounds())
+	return SubFallback(img).SubImage(ir)
+}
+
+// Rotate90 returns the image img rotated 90 degrees clockwise. The returned
+// image has the same concrete type as img where possible, and its bounds start
+// at (0, 0).
+func Rotate90(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	return transform(img, image.Rect(0, 0, h, w), func(x, y int) (int, int) {
+		return h - 1 - y, x
+	})
+}
+
+// Rotate180 returns the image img rotated 180 degrees. See Rotate90 for
+// details.
+func Rotate180(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	return transform(img, image.Rect(0, 0, w, h), func(x, y int) (int, int) {
+		return w - 1 - x, h - 1 - y
+	})
+}
+
+// Rotate270 returns the image img rotated 270 degrees clockwise (i.e. 90
+// degrees counter-clockwise). See Rotate90 for details.
+func Rotate270(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	return transform(img, image.Rect(0, 0, h, w), func(x, y int) (int, int) {
+		return y, w - 1 - x
+	})
+}
+
+// FlipH returns the image img flipped horizontally. See Rotate90 for details.
+func FlipH(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	return transform(img, image.Rect(0, 0, w, h), func(x, y int) (int, int) {
+		return w - 1 - x, y
+	})
+}
+
+// FlipV returns the image img flipped vertically. See Rotate90 for details.
+func FlipV(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	return transform(img, image.Rect(0, 0, w, h), func(x, y int) (int, int) {
+		return x, h - 1 - y
+	})
+}
+
+// transform returns a new image with the given bounds, where each pixel (x, y)
+// of img, relative to its minimum bounds, is copied to the pixel f(x, y) of the
+// new image.
+func transform(img image.Image, bounds image.Rectangle, f func(x, y int) (int, int)) image.Image {
+	b := img.Bounds()
+	dst := newLike(img, bounds)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dx, dy := f(x-b.Min.X, y-b.Min.Y)
+			dst.Set(dx, dy, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// Pad returns the image img padded with the given number of pixels on each
+// side, filled with the colour c. The returned image has the same concrete type
+// as img where possible, and its bounds start at (0, 0).
+func Pad(img image.Image, top, right, bottom, left int, c color.Color) image.Image {
+	b := img.Bounds()
+	dst := newLike(img, image.Rect(0, 0, left+b.Dx()+right, top+b.Dy()+bottom))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(dst, b.Sub(b.Min).Add(image.Pt(left, top)), img, b.Min, draw.Src)
+	return dst
+}
+
+// Trim returns the portion of the image img within the bounding box of its
+// pixels which are not fully transparent, along with the bounding box. The
+// returned image shares pixels with img. If every pixel is fully transparent,
+// the bounding box is empty.
+func Trim(img image.Image) (image.Image, image.Rectangle) {
+	b := img.Bounds()
+	var bbox image.Rectangle
+	row := make([]uint8, 4*b.Dx())
+	read := rowReader(img, b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		read(y, row)
+		first, last := -1, -1
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 0 {
+				if first == -1 {
+					first = i / 4
+				}
+				last = i / 4
+			}
+		}
+		if first != -1 {
+			bbox = bbox.Union(image.Rect(b.Min.X+first, y, b.Min.X+last+1, y+1))
+		}
+	}
+	return SubFallback(img).SubImage(bbox), bbox
+}
+
+// Composite returns a new image of the image fg alpha-composited over the image
+// bg, with the minimum bounds of fg positioned at off relative to the minimum
+// bounds of bg. The returned image has the bounds of bg and the same concrete
+// type as bg where possible.
+func Composite(bg, fg image.Image, off image.Point) image.Image {
+	b := bg.Bounds()
+	dst := newLike(bg, b)
+	draw.Draw(dst, b, bg, b.Min, draw.Src)
+	fb := fg.Bounds()
+	r := fb.Sub(fb.Min).Add(b.Min).Add(off)
+	draw.Draw(dst, r, fg, fb.Min, draw.Over)
+	return dst
+}
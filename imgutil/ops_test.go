@@ -0,0 +1,140 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewkiz/pkg/geometry"
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestCrop(t *testing.T) {
+	img := image.NewGray(image.Rect(10, 20, 20, 30))
+	golden := []struct {
+		r    geometry.Rectangle
+		want image.Rectangle
+	}{
+		{r: geometry.Rect(12, 22, 15, 24), want: image.Rect(12, 22, 15, 24)},
+		// Rounded outwards to whole pixels.
+		{r: geometry.Rect(12.5, 22.2, 14.1, 23.9), want: image.Rect(12, 22, 15, 24)},
+		// Clipped to the image bounds.
+		{r: geometry.Rect(5, 25, 15, 40), want: image.Rect(10, 25, 15, 30)},
+		// Outside of the image bounds.
+		{r: geometry.Rect(0, 0, 5, 5), want: image.Rectangle{}},
+	}
+	for _, g := range golden {
+		got := imgutil.Crop(img, g.r)
+		if got.Bounds() != g.want {
+			t.Errorf("bounds mismatch of %v; expected %v, got %v", g.r, g.want, got.Bounds())
+		}
+	}
+
+	// The cropped image shares pixels with img.
+	sub, ok := imgutil.Crop(img, geometry.Rect(12, 22, 15, 24)).(*image.Gray)
+	if !ok {
+		t.Fatalf("expected *image.Gray")
+	}
+	sub.SetGray(13, 23, color.Gray{Y: 0x80})
+	if got := img.GrayAt(13, 23).Y; got != 0x80 {
+		t.Errorf("shared pixel mismatch; expected %d, got %d", 0x80, got)
+	}
+}
+
+func TestComposite(t *testing.T) {
+	bg := image.NewNRGBA(image.Rect(10, 10, 14, 14))
+	fg := image.NewNRGBA(image.Rect(5, 5, 7, 7))
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	for y := 5; y < 7; y++ {
+		for x := 5; x < 7; x++ {
+			fg.SetNRGBA(x, y, red)
+		}
+	}
+	golden := []struct {
+		off image.Point
+		// Pixels of the result covered by fg.
+		want image.Rectangle
+	}{
+		{off: image.Pt(0, 0), want: image.Rect(10, 10, 12, 12)},
+		{off: image.Pt(1, 2), want: image.Rect(11, 12, 13, 14)},
+		// Partially out of bounds.
+		{off: image.Pt(3, 3), want: image.Rect(13, 13, 14, 14)},
+		{off: image.Pt(-1, -1), want: image.Rect(10, 10, 11, 11)},
+		// Entirely out of bounds.
+		{off: image.Pt(4, 0), want: image.Rectangle{}},
+		{off: image.Pt(-5, -5), want: image.Rectangle{}},
+	}
+	for _, g := range golden {
+		dst := imgutil.Composite(bg, fg, g.off)
+		got, ok := dst.(*image.NRGBA)
+		if !ok {
+			t.Errorf("offset %v: expected *image.NRGBA", g.off)
+			continue
+		}
+		if got.Bounds() != bg.Bounds() {
+			t.Errorf("offset %v: bounds mismatch; expected %v, got %v", g.off, bg.Bounds(), got.Bounds())
+			continue
+		}
+		for y := 10; y < 14; y++ {
+			for x := 10; x < 14; x++ {
+				var want color.NRGBA
+				if image.Pt(x, y).In(g.want) {
+					want = red
+				}
+				if c := got.NRGBAAt(x, y); c != want {
+					t.Errorf("offset %v: pixel mismatch at (%d, %d); expected %v, got %v", g.off, x, y, want, c)
+				}
+			}
+		}
+	}
+	if c := bg.NRGBAAt(10, 10); c != (color.NRGBA{}) {
+		t.Errorf("background modified; expected %v, got %v", color.NRGBA{}, c)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	// 3x2 image with distinct grey levels.
+	img := image.NewGray(image.Rect(10, 20, 13, 22))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i + 1)
+	}
+	golden := []struct {
+		name string
+		f    func(image.Image) image.Image
+		want []uint8
+		size image.Point
+	}{
+		{name: "Rotate90", f: imgutil.Rotate90, want: []uint8{4, 1, 5, 2, 6, 3}, size: image.Pt(2, 3)},
+		{name: "Rotate180", f: imgutil.Rotate180, want: []uint8{6, 5, 4, 3, 2, 1}, size: image.Pt(3, 2)},
+		{name: "Rotate270", f: imgutil.Rotate270, want: []uint8{3, 6, 2, 5, 1, 4}, size: image.Pt(2, 3)},
+		{name: "FlipH", f: imgutil.FlipH, want: []uint8{3, 2, 1, 6, 5, 4}, size: image.Pt(3, 2)},
+		{name: "FlipV", f: imgutil.FlipV, want: []uint8{4, 5, 6, 1, 2, 3}, size: image.Pt(3, 2)},
+	}
+	for _, g := range golden {
+		got, ok := g.f(img).(*image.Gray)
+		if !ok {
+			t.Errorf("%s: expected *image.Gray", g.name)
+			continue
+		}
+		if got.Bounds().Size() != g.size || string(got.Pix) != string(g.want) {
+			t.Errorf("%s: mismatch; expected %v of size %v, got %v of size %v", g.name, g.want, g.size, got.Pix, got.Bounds().Size())
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	img.SetNRGBA(3, 2, color.NRGBA{R: 0xFF, A: 0xFF})
+	img.SetNRGBA(6, 7, color.NRGBA{G: 0xFF, A: 0x01})
+	_, bbox := imgutil.Trim(img)
+	if want := image.Rect(3, 2, 7, 8); bbox != want {
+		t.Errorf("bounding box mismatch; expected %v, got %v", want, bbox)
+	}
+	padded := imgutil.Pad(img, 1, 2, 3, 4, color.Transparent)
+	if want := image.Rect(0, 0, 16, 14); padded.Bounds() != want {
+		t.Errorf("padded bounds mismatch; expected %v, got %v", want, padded.Bounds())
+	}
+	if _, bbox := imgutil.Trim(padded); bbox != image.Rect(7, 3, 11, 9) {
+		t.Errorf("padded bounding box mismatch; expected %v, got %v", image.Rect(7, 3, 11, 9), bbox)
+	}
+}
@@ -0,0 +1,195 @@
+package imgutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadPaletteFile reads a palette file specified by palPath. The palette format
+// is determined by the file contents and extension; JASC (.pal), GIMP (.gpl)
+// and raw RGB triplets (e.g. .act) are supported.
+func ReadPaletteFile(palPath string) (pal color.Palette, err error) {
+	buf, err := os.ReadFile(palPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := bytes.NewReader(buf)
+	switch {
+	case bytes.HasPrefix(buf, []byte("JASC-PAL")):
+		pal, err = ReadJASCPalette(r)
+	case bytes.HasPrefix(buf, []byte("GIMP Palette")):
+		pal, err = ReadGIMPPalette(r)
+	default:
+		pal, err = ReadRawPalette(r)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse palette %q", palPath)
+	}
+	return pal, nil
+}
+
+// WritePaletteFile writes the palette to a file specified by palPath. The
+// palette format is determined by the file extension; JASC (.pal), GIMP (.gpl)
+// and raw RGB triplets (any other extension) are supported.
+func WritePaletteFile(palPath string, pal color.Palette) error {
+	return writeFileAtomic(palPath, func(w io.Writer) error {
+		switch strings.ToLower(filepath.Ext(palPath)) {
+		case ".pal":
+			return WriteJASCPalette(w, pal)
+		case ".gpl":
+			name := strings.TrimSuffix(filepath.Base(palPath), filepath.Ext(palPath))
+			return WriteGIMPPalette(w, pal, name)
+		default:
+			return WriteRawPalette(w, pal)
+		}
+	})
+}
+
+// ReadJASCPalette reads a palette in JASC (Paint Shop Pro) format from r.
+func ReadJASCPalette(r io.Reader) (color.Palette, error) {
+	s := bufio.NewScanner(r)
+	var lines []string
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(lines) < 3 || lines[0] != "JASC-PAL" {
+		return nil, errors.New("invalid JASC palette header")
+	}
+	n, err := strconv.Atoi(lines[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JASC palette colour count")
+	}
+	if len(lines)-3 < n {
+		return nil, errors.Errorf("JASC palette colour count mismatch; expected %d, got %d", n, len(lines)-3)
+	}
+	pal := make(color.Palette, n)
+	for i, line := range lines[3 : 3+n] {
+		c, err := parseRGB(strings.Fields(line))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid JASC palette entry %d", i)
+		}
+		pal[i] = c
+	}
+	return pal, nil
+}
+
+// WriteJASCPalette writes the palette in JASC (Paint Shop Pro) format to w.
+func WriteJASCPalette(w io.Writer, pal color.Palette) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "JASC-PAL\r\n0100\r\n%d\r\n", len(pal))
+	for _, c := range pal {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%d %d %d\r\n", nc.R, nc.G, nc.B)
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ReadGIMPPalette reads a palette in GIMP (.gpl) format from r.
+func ReadGIMPPalette(r io.Reader) (color.Palette, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() || strings.TrimSpace(s.Text()) != "GIMP Palette" {
+		if err := s.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nil, errors.New("invalid GIMP palette header")
+	}
+	var pal color.Palette
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			// Skip empty lines, comments and Name/Columns attributes.
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, errors.Errorf("invalid GIMP palette entry %q", line)
+		}
+		c, err := parseRGB(fields[:3])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid GIMP palette entry %q", line)
+		}
+		pal = append(pal, c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pal, nil
+}
+
+// WriteGIMPPalette writes the palette with the given name in GIMP (.gpl)
+// format to w.
+func WriteGIMPPalette(w io.Writer, pal color.Palette, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "GIMP Palette\nName: %s\nColumns: 16\n#\n", name)
+	for i, c := range pal {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%3d %3d %3d\tIndex %d\n", nc.R, nc.G, nc.B, i)
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ReadRawPalette reads a palette of raw RGB triplets from r.
+func ReadRawPalette(r io.Reader) (color.Palette, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(buf)%3 != 0 {
+		return nil, errors.Errorf("invalid raw palette size %d; not a multiple of 3", len(buf))
+	}
+	pal := make(color.Palette, len(buf)/3)
+	for i := range pal {
+		pal[i] = color.NRGBA{R: buf[3*i], G: buf[3*i+1], B: buf[3*i+2], A: 0xFF}
+	}
+	return pal, nil
+}
+
+// WriteRawPalette writes the palette as raw RGB triplets to w.
+func WriteRawPalette(w io.Writer, pal color.Palette) error {
+	buf := make([]byte, 0, 3*len(pal))
+	for _, c := range pal {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		buf = append(buf, nc.R, nc.G, nc.B)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// parseRGB parses the given decimal red, green and blue colour channels.
+func parseRGB(fields []string) (color.NRGBA, error) {
+	if len(fields) != 3 {
+		return color.NRGBA{}, errors.Errorf("invalid number of colour channels; expected 3, got %d", len(fields))
+	}
+	var c [3]uint8
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 8)
+		if err != nil {
+			return color.NRGBA{}, errors.WithStack(err)
+		}
+		c[i] = uint8(v)
+	}
+	return color.NRGBA{R: c[0], G: c[1], B: c[2], A: 0xFF}, nil
+}
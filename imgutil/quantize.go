@@ -0,0 +1,290 @@
+package imgutil
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+// ToPaletted converts the image img to a paletted image using the given
+// palette, mapping each pixel to the closest palette colour. If dither is set,
+// Floyd-Steinberg error diffusion is used.
+func ToPaletted(img image.Image, pal color.Palette, dither bool) *image.Paletted {
+	bounds := img.Bounds()
+	dst := image.NewPaletted(bounds, pal)
+	if dither {
+		draw.FloydSteinberg.Draw(dst, bounds, img, bounds.Min)
+	} else {
+		draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	}
+	return dst
+}
+
+// Quantize converts the image img to a paletted image of at most n colours,
+// using a palette generated by MedianCut. If dither is set, Floyd-Steinberg
+// error diffusion is used.
+func Quantize(img image.Image, n int, dither bool) *image.Paletted {
+	return ToPaletted(img, MedianCut(img, n), dither)
+}
+
+// histEntry is a colour histogram entry.
+type histEntry struct {
+	// Colour channels (red, green and blue).
+	c [3]uint8
+	// Number of pixels of the colour.
+	count int
+}
+
+// histogram returns the colour histogram of the opaque pixels of img, and
+// reports whether img contains any translucent pixels (alpha < 50%). Colours
+// are non-alpha-premultiplied.
+func histogram(img image.Image) (hist []histEntry, translucent bool) {
+	bounds := img.Bounds()
+	counts := make(map[[3]uint8]int)
+	row := make([]uint8, 4*bounds.Dx())
+	read := rowReader(img, bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		read(y, row)
+		for i := 0; i < len(row); i += 4 {
+			a := row[i+3]
+			if a < 0x80 {
+				translucent = true
+				continue
+			}
+			// Undo alpha-premultiplication.
+			r := uint8(uint32(row[i]) * 0xFF / uint32(a))
+			g := uint8(uint32(row[i+1]) * 0xFF / uint32(a))
+			b := uint8(uint32(row[i+2]) * 0xFF / uint32(a))
+			counts[[3]uint8{r, g, b}]++
+		}
+	}
+	hist = make([]histEntry, 0, len(counts))
+	for c, count := range counts {
+		hist = append(hist, histEntry{c: c, count: count})
+	}
+	// Sort for deterministic output.
+	slices.SortFunc(hist, func(a, b histEntry) int {
+		return cmp.Or(cmp.Compare(a.c[0], b.c[0]), cmp.Compare(a.c[1], b.c[1]), cmp.Compare(a.c[2], b.c[2]))
+	})
+	return hist, translucent
+}
+
+// MedianCut returns a palette of at most n colours representative of the image
+// img, generated using the median cut algorithm. If img contains translucent
+// pixels, the first palette entry is fully transparent.
+func MedianCut(img image.Image, n int) color.Palette {
+	hist, translucent := histogram(img)
+	var pal color.Palette
+	if translucent {
+		pal = append(pal, color.NRGBA{})
+		n--
+	}
+	for _, c := range medianCut(hist, n) {
+		pal = append(pal, c)
+	}
+	return pal
+}
+
+// medianCut returns at most n colours representative of the given colour
+// histogram, generated using the median cut algorithm.
+func medianCut(hist []histEntry, n int) []color.NRGBA {
+	if n <= 0 || len(hist) == 0 {
+		return nil
+	}
+	// Split a copy, as boxes are sorted in place.
+	boxes := [][]histEntry{slices.Clone(hist)}
+	for len(boxes) < n {
+		// Split the box with the widest channel range.
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, rng := widestChannel(box)
+			if rng > bestRange {
+				best, bestChannel, bestRange = i, channel, rng
+			}
+		}
+		if best == -1 {
+			// Each box contains a single colour.
+			break
+		}
+		box := boxes[best]
+		slices.SortStableFunc(box, func(a, b histEntry) int {
+			return cmp.Compare(a.c[bestChannel], b.c[bestChannel])
+		})
+		// Split at the weighted median.
+		var total, acc int
+		for _, e := range box {
+			total += e.count
+		}
+		split := 1
+		for i, e := range box[:len(box)-1] {
+			acc += e.count
+			if 2*acc >= total {
+				split = i + 1
+				break
+			}
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	colors := make([]color.NRGBA, len(boxes))
+	for i, box := range boxes {
+		colors[i] = averageColor(box)
+	}
+	return colors
+}
+
+// widestChannel returns the colour channel with the widest range of values in
+// the given histogram entries, along with its range.
+func widestChannel(box []histEntry) (channel, rng int) {
+	lo := [3]uint8{0xFF, 0xFF, 0xFF}
+	var hi [3]uint8
+	for _, e := range box {
+		for c := range 3 {
+			lo[c] = min(lo[c], e.c[c])
+			hi[c] = max(hi[c], e.c[c])
+		}
+	}
+	for c := range 3 {
+		if r := int(hi[c]) - int(lo[c]); r > rng {
+			channel, rng = c, r
+		}
+	}
+	return channel, rng
+}
+
+// averageColor returns the weighted average colour of the given histogram
+// entries.
+func averageColor(box []histEntry) color.NRGBA {
+	var sum [3]int
+	var total int
+	for _, e := range box {
+		for c := range 3 {
+			sum[c] += int(e.c[c]) * e.count
+		}
+		total += e.count
+	}
+	return color.NRGBA{
+		R: uint8((sum[0] + total/2) / total),
+		G: uint8((sum[1] + total/2) / total),
+		B: uint8((sum[2] + total/2) / total),
+		A: 0xFF,
+	}
+}
+
+// octreeDepth is the depth of the colour octree.
+const octreeDepth = 8
+
+// octreeNode is a node of a colour octree.
+type octreeNode struct {
+	// Child nodes; nil for leaves.
+	children [8]*octreeNode
+	// Leaf node.
+	leaf bool
+	// Number of pixels and sum of their colour channels.
+	count   int
+	r, g, b int
+}
+
+// Octree returns a palette of at most n colours representative of the image
+// img, generated using octree colour quantisation. If img contains translucent
+// pixels, the first palette entry is fully transparent.
+func Octree(img image.Image, n int) color.Palette {
+	hist, translucent := histogram(img)
+	var pal color.Palette
+	if translucent {
+		pal = append(pal, color.NRGBA{})
+		n--
+	}
+	if n <= 0 || len(hist) == 0 {
+		return pal
+	}
+	root := &octreeNode{}
+	// Reducible nodes of each level.
+	var levels [octreeDepth][]*octreeNode
+	leaves := 0
+	for _, e := range hist {
+		node := root
+		for level := 0; level < octreeDepth; level++ {
+			shift := 7 - level
+			i := int(e.c[0]>>shift&1)<<2 | int(e.c[1]>>shift&1)<<1 | int(e.c[2]>>shift&1)
+			if node.children[i] == nil {
+				child := &octreeNode{leaf: level == octreeDepth-1}
+				node.children[i] = child
+				if child.leaf {
+					leaves++
+				} else {
+					levels[level+1] = append(levels[level+1], child)
+				}
+			}
+			node = node.children[i]
+		}
+		node.count += e.count
+		node.r += int(e.c[0]) * e.count
+		node.g += int(e.c[1]) * e.count
+		node.b += int(e.c[2]) * e.count
+	}
+	levels[0] = []*octreeNode{root}
+	// Merge the children of the deepest reducible nodes until at most n leaves
+	// remain.
+	for level := octreeDepth - 1; level >= 0 && leaves > n; level-- {
+		nodes := levels[level]
+		// Merge nodes of few pixels first.
+		slices.SortStableFunc(nodes, func(a, b *octreeNode) int {
+			return cmp.Compare(a.pixels(), b.pixels())
+		})
+		for _, node := range nodes {
+			if leaves <= n {
+				break
+			}
+			merged := 0
+			for i, child := range node.children {
+				if child == nil {
+					continue
+				}
+				node.count += child.count
+				node.r += child.r
+				node.g += child.g
+				node.b += child.b
+				node.children[i] = nil
+				merged++
+			}
+			node.leaf = true
+			leaves += 1 - merged
+		}
+	}
+	var walk func(node *octreeNode)
+	walk = func(node *octreeNode) {
+		if node.leaf {
+			pal = append(pal, color.NRGBA{
+				R: uint8((node.r + node.count/2) / node.count),
+				G: uint8((node.g + node.count/2) / node.count),
+				B: uint8((node.b + node.count/2) / node.count),
+				A: 0xFF,
+			})
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return pal
+}
+
+// pixels returns the number of pixels within the subtree rooted at node.
+func (node *octreeNode) pixels() int {
+	n := node.count
+	for _, child := range node.children {
+		if child != nil {
+			n += child.pixels()
+		}
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestQuantize(t *testing.T) {
+	// Image of four distinct colours and a transparent region.
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	colors := []color.NRGBA{
+		{R: 0xFF, A: 0xFF},
+		{G: 0xFF, A: 0xFF},
+		{B: 0xFF, A: 0xFF},
+		{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 12; x++ {
+			img.SetNRGBA(x, y, colors[(x/3+y)%4])
+		}
+	}
+	for name, quantize := range map[string]func(image.Image, int) color.Palette{
+		"MedianCut": imgutil.MedianCut,
+		"Octree":    imgutil.Octree,
+	} {
+		pal := quantize(img, 5)
+		if len(pal) != 5 {
+			t.Errorf("%s: palette size mismatch; expected 5, got %d", name, len(pal))
+			continue
+		}
+		if pal[0] != (color.NRGBA{}) {
+			t.Errorf("%s: expected transparent first palette entry, got %v", name, pal[0])
+		}
+		dst := imgutil.ToPaletted(img, pal, false)
+		if !imgutil.Equal(img, dst) {
+			t.Errorf("%s: quantized image mismatch", name)
+		}
+	}
+}
+
+func TestMedianCutTranslucent(t *testing.T) {
+	// Alpha-premultiplied image of a semi-opaque colour and a mostly
+	// transparent region.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	want := color.NRGBA{R: 200, G: 100, B: 50, A: 0xFF}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.NRGBA{R: want.R, G: want.G, B: want.B, A: 0xC0}
+			if x == 0 {
+				c.A = 0x40
+			}
+			img.Set(x, y, c)
+		}
+	}
+	pal := imgutil.MedianCut(img, 4)
+	if len(pal) != 2 {
+		t.Fatalf("palette size mismatch; expected 2, got %d", len(pal))
+	}
+	if pal[0] != (color.NRGBA{}) {
+		t.Errorf("expected transparent first palette entry, got %v", pal[0])
+	}
+	// Colours are non-alpha-premultiplied, within rounding errors of 8-bit
+	// alpha-premultiplication.
+	got := pal[1].(color.NRGBA)
+	for c, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B)} {
+		if d < -1 || d > 1 {
+			t.Errorf("channel %d mismatch; expected %v, got %v", c, want, got)
+		}
+	}
+	if got.A != 0xFF {
+		t.Errorf("alpha mismatch; expected %d, got %d", 0xFF, got.A)
+	}
+}
+
+func TestPaletteFormats(t *testing.T) {
+	pal := color.Palette{
+		color.NRGBA{R: 1, G: 2, B: 3, A: 0xFF},
+		color.NRGBA{R: 0xFF, G: 0x80, B: 0, A: 0xFF},
+	}
+	golden := []struct {
+		write func(buf *bytes.Buffer) error
+		read  func(buf *bytes.Buffer) (color.Palette, error)
+	}{
+		{
+			write: func(buf *bytes.Buffer) error { return imgutil.WriteJASCPalette(buf, pal) },
+			read:  func(buf *bytes.Buffer) (color.Palette, error) { return imgutil.ReadJASCPalette(buf) },
+		},
+		{
+			write: func(buf *bytes.Buffer) error { return imgutil.WriteGIMPPalette(buf, pal, "test") },
+			read:  func(buf *bytes.Buffer) (color.Palette, error) { return imgutil.ReadGIMPPalette(buf) },
+		},
+		{
+			write: func(buf *bytes.Buffer) error { return imgutil.WriteRawPalette(buf, pal) },
+			read:  func(buf *bytes.Buffer) (color.Palette, error) { return imgutil.ReadRawPalette(buf) },
+		},
+	}
+	for i, g := range golden {
+		buf := &bytes.Buffer{}
+		if err := g.write(buf); err != nil {
+			t.Errorf("%d: unable to write palette; %v", i, err)
+			continue
+		}
+		got, err := g.read(buf)
+		if err != nil {
+			t.Errorf("%d: unable to read palette; %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(pal, got) {
+			t.Errorf("%d: palette mismatch; expected %v, got %v", i, pal, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Interpolation kernels used for resampling images.
+var (
+	// NearestNeighbor is the nearest neighbor interpolator. It is very fast, but
+	// usually gives very low quality results.
+	NearestNeighbor = xdraw.NearestNeighbor
+	// BiLinear is the tent kernel. It is slow, but usually gives high quality
+	// results.
+	BiLinear = xdraw.BiLinear
+	// CatmullRom is the Catmull-Rom kernel. It is very slow, but usually gives
+	// very high quality results.
+	CatmullRom = xdraw.CatmullRom
+)
+
+// Anchor specifies the part of an image retained when cropping.
+type Anchor uint8
+
+// Anchor points.
+const (
+	AnchorCenter Anchor = iota
+	AnchorTopLeft
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// Resize returns the image src scaled to width x height pixels using the given
+// interpolator, or CatmullRom if interp is nil. If either width or height is 0,
+// it is computed from the other to preserve the aspect ratio of src. The
+// returned image has the same concrete type as src where possible, and its
+// bounds start at (0, 0).
+func Resize(src image.Image, width, height int, interp xdraw.Interpolator) image.Image {
+	sr := src.Bounds()
+	switch {
+	case width == 0 && height == 0:
+		width, height = sr.Dx(), sr.Dy()
+	case width == 0:
+		width = scaleDim(sr.Dx(), height, sr.Dy())
+	case height == 0:
+		height = scaleDim(sr.Dy(), width, sr.Dx())
+	}
+	return scale(src, sr, image.Rect(0, 0, width, height), interp)
+}
+
+// Fit returns the image src scaled to fit within width x height pixels,
+// preserving its aspect ratio. If either width or height is 0, src is scaled to
+// the other. See Resize for details.
+func Fit(src image.Image, width, height int, interp xdraw.Interpolator) image.Image {
+	sr := src.Bounds()
+	if sr.Dx() == 0 || sr.Dy() == 0 || width == 0 || height == 0 {
+		return Resize(src, width, height, interp)
+	}
+	// Compare width/sr.Dx() against height/sr.Dy().
+	if width*sr.Dy() <= height*sr.Dx() {
+		return Resize(src, width, 0, interp)
+	}
+	return Resize(src, 0, height, interp)
+}
+
+// Fill returns the image src scaled to cover width x height pixels, preserving
+// its aspect ratio, and cropped to exactly width x height pixels. The anchor
+// specifies which part of the scaled image is retained. See Resize for
+// details.
+func Fill(src image.Image, width, height int, anchor Anchor, interp xdraw.Interpolator) image.Image {
+	sr := src.Bounds()
+	if sr.Dx() == 0 || sr.Dy() == 0 || width == 0 || height == 0 {
+		return Resize(src, width, height, interp)
+	}
+	// Crop the source image to the aspect ratio of the destination before
+	// scaling, to avoid resampling pixels which are cropped anyway.
+	cw, ch := sr.Dx(), sr.Dy()
+	if width*sr.Dy() <= height*sr.Dx() {
+		cw = scaleDim(width, sr.Dy(), height)
+	} else {
+		ch = scaleDim(height, sr.Dx(), width)
+	}
+	crop := anchorRect(sr, cw, ch, anchor)
+	return scale(src, crop, image.Rect(0, 0, width, height), interp)
+}
+
+// anchorRect returns a rectangle of size w x h within r, positioned according
+// to the given anchor.
+func anchorRect(r image.Rectangle, w, h int, anchor Anchor) image.Rectangle {
+	x, y := r.Min.X+(r.Dx()-w)/2, r.Min.Y+(r.Dy()-h)/2
+	switch anchor {
+	case AnchorTopLeft, AnchorLeft, AnchorBottomLeft:
+		x = r.Min.X
+	case AnchorTopRight, AnchorRight, AnchorBottomRight:
+		x = r.Max.X - w
+	}
+	switch anchor {
+	case AnchorTopLeft, AnchorTop, AnchorTopRight:
+		y = r.Min.Y
+	case AnchorBottomLeft, AnchorBottom, AnchorBottomRight:
+		y = r.Max.Y - h
+	}
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// scale returns the part sr of the image src scaled to dr using the given
+// interpolator, or CatmullRom if interp is nil.
+func scale(src image.Image, sr, dr image.Rectangle, interp xdraw.Interpolator) image.Image {
+	if interp == nil {
+		interp = CatmullRom
+	}
+	dst := newLike(src, dr)
+	interp.Scale(dst, dr, src, sr, xdraw.Src, nil)
+	return dst
+}
+
+// scaleDim returns n scaled by num/denom, rounded to the nearest integer and at
+// least 1.
+func scaleDim(n, num, denom int) int {
+	if denom == 0 {
+		return n
+	}
+	v := int(math.Round(float64(n) * float64(num) / float64(denom)))
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+// newLike returns a new image with the given bounds and the same concrete type
+// as src where possible, or an *image.NRGBA otherwise.
+func newLike(src image.Image, r image.Rectangle) draw.Image {
+	switch src := src.(type) {
+	case *image.RGBA:
+		return image.NewRGBA(r)
+	case *image.NRGBA:
+		return image.NewNRGBA(r)
+	case *image.RGBA64:
+		return image.NewRGBA64(r)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(r)
+	case *image.Gray:
+		return image.NewGray(r)
+	case *image.Gray16:
+		return image.NewGray16(r)
+	case *image.Alpha:
+		return image.NewAlpha(r)
+	case *image.Alpha16:
+		return image.NewAlpha16(r)
+	case *image.CMYK:
+		return image.NewCMYK(r)
+	case *image.Paletted:
+		pal := make(color.Palette, len(src.Palette))
+		copy(pal, src.Palette)
+		return image.NewPaletted(r, pal)
+	default:
+		return image.NewNRGBA(r)
+	}
+}
@@ -0,0 +1,79 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestResizeSize(t *testing.T) {
+	src := image.NewGray(image.Rect(10, 10, 110, 60))
+	golden := []struct {
+		name string
+		f    func() image.Image
+		want image.Rectangle
+	}{
+		{name: "Resize(40, 30)", f: func() image.Image { return imgutil.Resize(src, 40, 30, nil) }, want: image.Rect(0, 0, 40, 30)},
+		{name: "Resize(0, 20)", f: func() image.Image { return imgutil.Resize(src, 0, 20, nil) }, want: image.Rect(0, 0, 40, 20)},
+		{name: "Resize(20, 0)", f: func() image.Image { return imgutil.Resize(src, 20, 0, nil) }, want: image.Rect(0, 0, 20, 10)},
+		{name: "Resize(0, 0)", f: func() image.Image { return imgutil.Resize(src, 0, 0, nil) }, want: image.Rect(0, 0, 100, 50)},
+		{name: "Fit(40, 40)", f: func() image.Image { return imgutil.Fit(src, 40, 40, nil) }, want: image.Rect(0, 0, 40, 20)},
+		{name: "Fit(400, 40)", f: func() image.Image { return imgutil.Fit(src, 400, 40, nil) }, want: image.Rect(0, 0, 80, 40)},
+		{name: "Fit(0, 20)", f: func() image.Image { return imgutil.Fit(src, 0, 20, nil) }, want: image.Rect(0, 0, 40, 20)},
+		{name: "Fit(20, 0)", f: func() image.Image { return imgutil.Fit(src, 20, 0, nil) }, want: image.Rect(0, 0, 20, 10)},
+		{name: "Fill(20, 20)", f: func() image.Image { return imgutil.Fill(src, 20, 20, imgutil.AnchorCenter, nil) }, want: image.Rect(0, 0, 20, 20)},
+		{name: "Fill(0, 20)", f: func() image.Image { return imgutil.Fill(src, 0, 20, imgutil.AnchorCenter, nil) }, want: image.Rect(0, 0, 40, 20)},
+	}
+	for _, g := range golden {
+		got := g.f()
+		if got.Bounds() != g.want {
+			t.Errorf("%s: bounds mismatch; expected %v, got %v", g.name, g.want, got.Bounds())
+		}
+		if _, ok := got.(*image.Gray); !ok {
+			t.Errorf("%s: image type mismatch; expected *image.Gray, got %T", g.name, got)
+		}
+	}
+}
+
+func TestFillAnchor(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	green := color.NRGBA{G: 0xFF, A: 0xFF}
+	blue := color.NRGBA{B: 0xFF, A: 0xFF}
+	// Horizontal (6x2) and vertical (2x6) images of three 2x2 bands.
+	horiz := image.NewNRGBA(image.Rect(0, 0, 6, 2))
+	vert := image.NewNRGBA(image.Rect(0, 0, 2, 6))
+	for i, c := range []color.NRGBA{red, green, blue} {
+		for j := 0; j < 2; j++ {
+			for k := 0; k < 2; k++ {
+				horiz.SetNRGBA(2*i+j, k, c)
+				vert.SetNRGBA(k, 2*i+j, c)
+			}
+		}
+	}
+	golden := []struct {
+		src    image.Image
+		anchor imgutil.Anchor
+		want   color.NRGBA
+	}{
+		{src: horiz, anchor: imgutil.AnchorLeft, want: red},
+		{src: horiz, anchor: imgutil.AnchorTopLeft, want: red},
+		{src: horiz, anchor: imgutil.AnchorCenter, want: green},
+		{src: horiz, anchor: imgutil.AnchorTop, want: green},
+		{src: horiz, anchor: imgutil.AnchorRight, want: blue},
+		{src: horiz, anchor: imgutil.AnchorBottomRight, want: blue},
+		{src: vert, anchor: imgutil.AnchorTop, want: red},
+		{src: vert, anchor: imgutil.AnchorTopRight, want: red},
+		{src: vert, anchor: imgutil.AnchorCenter, want: green},
+		{src: vert, anchor: imgutil.AnchorLeft, want: green},
+		{src: vert, anchor: imgutil.AnchorBottom, want: blue},
+		{src: vert, anchor: imgutil.AnchorBottomLeft, want: blue},
+	}
+	for i, g := range golden {
+		dst := imgutil.Fill(g.src, 1, 1, g.anchor, imgutil.NearestNeighbor)
+		if got := color.NRGBAModel.Convert(dst.At(0, 0)); got != g.want {
+			t.Errorf("i=%d: anchor %d colour mismatch; expected %v, got %v", i, g.anchor, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package imgutil
+
+import (
+	"encoding/json"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+
+	"github.com/mewkiz/pkg/csvutil"
+	"github.com/mewkiz/pkg/geometry"
+	"github.com/pkg/errors"
+)
+
+// GridOptions specifies the layout of a grid of cells in a sprite sheet.
+type GridOptions struct {
+	// Width and height of each cell in pixels.
+	CellWidth, CellHeight int
+	// Number of pixels between the image border and the first cell.
+	Margin int
+	// Number of pixels between adjacent cells.
+	Spacing int
+	// Skip cells in which every pixel is fully transparent.
+	SkipEmpty bool
+}
+
+// A Cell is a cell of a sprite sheet.
+type Cell struct {
+	// Column and row of the cell in the grid.
+	Col, Row int
+	// Bounds of the cell in the sprite sheet.
+	Bounds image.Rectangle
+	// Subimage of the cell, sharing pixels with the sprite sheet where
+	// possible.
+	Image image.Image
+}
+
+// SliceGrid slices the image img into a grid of cells, in row-major order. Only
+// complete cells are returned.
+func SliceGrid(img image.Image, opts GridOptions) ([]Cell, error) {
+	if opts.CellWidth <= 0 || opts.CellHeight <= 0 {
+		return nil, errors.Errorf("invalid cell size %dx%d", opts.CellWidth, opts.CellHeight)
+	}
+	sub := SubFallback(img)
+	bounds := img.Bounds()
+	var cells []Cell
+	for row, y := 0, bounds.Min.Y+opts.Margin; y+opts.CellHeight <= bounds.Max.Y; row, y = row+1, y+opts.CellHeight+opts.Spacing {
+		for col, x := 0, bounds.Min.X+opts.Margin; x+opts.CellWidth <= bounds.Max.X; col, x = col+1, x+opts.CellWidth+opts.Spacing {
+			r := image.Rect(x, y, x+opts.CellWidth, y+opts.CellHeight)
+			if opts.SkipEmpty && isTransparent(img, r) {
+				continue
+			}
+			cells = append(cells, Cell{
+				Col:    col,
+				Row:    row,
+				Bounds: r,
+				Image:  sub.SubImage(r),
+			})
+		}
+	}
+	return cells, nil
+}
+
+// isTransparent reports whether every pixel of img within r is fully
+// transparent.
+func isTransparent(img image.Image, r image.Rectangle) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return false
+	}
+	row := make([]uint8, 4*r.Dx())
+	read := rowReader(img, r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		read(y, row)
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A Sprite is a named image to be assembled into a sprite sheet.
+type Sprite struct {
+	// Name of the sprite.
+	Name string
+	// Image of the sprite.
+	Image image.Image
+}
+
+// SheetOptions specifies the layout of an assembled sprite sheet.
+type SheetOptions struct {
+	// Number of columns of the grid layout. If 0, a roughly square grid is used.
+	Columns int
+	// Number of pixels between the image border and the first cell.
+	Margin int
+	// Number of pixels between adjacent cells.
+	Spacing int
+	// Pack sprites tightly instead of using a grid layout, with the sheet size
+	// limited to MaxWidth x MaxHeight pixels.
+	Pack bool
+	// Maximum width and height of packed sprite sheets.
+	MaxWidth, MaxHeight int
+}
+
+// A SheetEntry specifies the location of a sprite in a sprite sheet.
+type SheetEntry struct {
+	// Name of the sprite.
+	Name string `json:"name" csv:"name"`
+	// Bounds of the sprite in the sprite sheet.
+	X      int `json:"x" csv:"x"`
+	Y      int `json:"y" csv:"y"`
+	Width  int `json:"width" csv:"width"`
+	Height int `json:"height" csv:"height"`
+}
+
+// Rect returns the bounds of the sprite in the sprite sheet.
+func (e SheetEntry) Rect() image.Rectangle {
+	return image.Rect(e.X, e.Y, e.X+e.Width, e.Y+e.Height)
+}
+
+// AssembleSheet assembles the given sprites into a sprite sheet, and returns
+// the sheet along with an index of the location of each sprite. Sprites are
+// placed in the cells of a grid, the size of which is determined by the largest
+// sprite, unless opts.Pack is set. If opts is nil, default options are used.
+func AssembleSheet(sprites []Sprite, opts *SheetOptions) (*image.NRGBA, []SheetEntry, error) {
+	if opts == nil {
+		opts = &SheetOptions{}
+	}
+	var entries []SheetEntry
+	var size image.Point
+	var err error
+	if opts.Pack {
+		entries, size, err = packSheet(sprites, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		entries, size = gridSheet(sprites, opts)
+	}
+	sheet := image.NewNRGBA(image.Rectangle{Max: size})
+	for i, sprite := range sprites {
+		sr := sprite.Image.Bounds()
+		draw.Draw(sheet, entries[i].Rect(), sprite.Image, sr.Min, draw.Src)
+	}
+	return sheet, entries, nil
+}
+
+// gridSheet lays out the given sprites in a grid, and returns the location of
+// each sprite and the size of the sprite sheet.
+func gridSheet(sprites []Sprite, opts *SheetOptions) ([]SheetEntry, image.Point) {
+	var cell image.Point
+	for _, sprite := range sprites {
+		sr := sprite.Image.Bounds()
+		cell.X = max(cell.X, sr.Dx())
+		cell.Y = max(cell.Y, sr.Dy())
+	}
+	cols := opts.Columns
+	if cols <= 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(len(sprites)))))
+	}
+	cols = max(min(cols, len(sprites)), 1)
+	rows := (len(sprites) + cols - 1) / cols
+	entries := make([]SheetEntry, len(sprites))
+	for i, sprite := range sprites {
+		sr := sprite.Image.Bounds()
+		col, row := i%cols, i/cols
+		entries[i] = SheetEntry{
+			Name:   sprite.Name,
+			X:      opts.Margin + col*(cell.X+opts.Spacing),
+			Y:      opts.Margin + row*(cell.Y+opts.Spacing),
+			Width:  sr.Dx(),
+			Height: sr.Dy(),
+		}
+	}
+	size := image.Point{
+		X: 2*opts.Margin + cols*cell.X + max(cols-1, 0)*opts.Spacing,
+		Y: 2*opts.Margin + rows*cell.Y + max(rows-1, 0)*opts.Spacing,
+	}
+	return entries, size
+}
+
+// packSheet packs the given sprites tightly into a single sprite sheet, and
+// returns the location of each sprite and the size of the sprite sheet.
+func packSheet(sprites []Sprite, opts *SheetOptions) ([]SheetEntry, image.Point, error) {
+	sizes := make([]geometry.Point, len(sprites))
+	var area int
+	for i, sprite := range sprites {
+		sr := sprite.Image.Bounds()
+		sizes[i] = geometry.Pt(float64(sr.Dx()), float64(sr.Dy()))
+		area += (sr.Dx() + opts.Spacing) * (sr.Dy() + opts.Spacing)
+	}
+	maxW, maxH := opts.MaxWidth-2*opts.Margin, opts.MaxHeight-2*opts.Margin
+	if maxW <= 0 || maxH <= 0 {
+		return nil, image.Point{}, errors.Errorf("invalid maximum sprite sheet size %dx%d", opts.MaxWidth, opts.MaxHeight)
+	}
+	// Start from a power of two bin covering the total sprite area.
+	side := 1
+	for side*side < area {
+		side *= 2
+	}
+	packOpts := geometry.PackOptions{
+		Width:     float64(min(side, maxW)),
+		Height:    float64(min(side, maxH)),
+		Grow:      true,
+		MaxWidth:  float64(maxW),
+		MaxHeight: float64(maxH),
+		Padding:   float64(opts.Spacing),
+	}
+	placements, bins, err := geometry.Pack(sizes, packOpts)
+	if err != nil {
+		return nil, image.Point{}, errors.WithStack(err)
+	}
+	if len(bins) > 1 {
+		return nil, image.Point{}, errors.Errorf("unable to pack %d sprites into sprite sheet of size %dx%d", len(sprites), opts.MaxWidth, opts.MaxHeight)
+	}
+	entries := make([]SheetEntry, len(sprites))
+	var used image.Rectangle
+	for i, p := range placements {
+		r := p.Rect.Image(geometry.RoundNearest).Add(image.Pt(opts.Margin, opts.Margin))
+		used = used.Union(r)
+		entries[i] = SheetEntry{
+			Name:   sprites[i].Name,
+			X:      r.Min.X,
+			Y:      r.Min.Y,
+			Width:  r.Dx(),
+			Height: r.Dy(),
+		}
+	}
+	size := image.Pt(used.Max.X+opts.Margin, used.Max.Y+opts.Margin)
+	return entries, size, nil
+}
+
+// WriteSheetJSON writes the sprite sheet index in JSON format to w.
+func WriteSheetJSON(w io.Writer, entries []SheetEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(entries); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WriteSheetCSV writes the sprite sheet index in CSV format to w.
+func WriteSheetCSV(w io.Writer, entries []SheetEntry) error {
+	return csvutil.Write(w, entries)
+}
@@ -0,0 +1,77 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestSheet(t *testing.T) {
+	var sprites []imgutil.Sprite
+	for i := 0; i < 5; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 8+i, 8))
+		for j := 3; j < len(img.Pix); j += 4 {
+			img.Pix[j-1] = uint8(40 * i)
+			img.Pix[j] = 0xFF
+		}
+		sprites = append(sprites, imgutil.Sprite{Name: string(rune('a' + i)), Image: img})
+	}
+	golden := []*imgutil.SheetOptions{
+		{Columns: 3, Margin: 1, Spacing: 2},
+		{Pack: true, Spacing: 1, MaxWidth: 64, MaxHeight: 64},
+	}
+	for _, opts := range golden {
+		sheet, entries, err := imgutil.AssembleSheet(sprites, opts)
+		if err != nil {
+			t.Errorf("unable to assemble sprite sheet; %v", err)
+			continue
+		}
+		for i, e := range entries {
+			if !e.Rect().In(sheet.Bounds()) {
+				t.Errorf("sprite %q at %v outside of sheet %v", e.Name, e.Rect(), sheet.Bounds())
+				continue
+			}
+			sub := sheet.SubImage(e.Rect())
+			if !imgutil.Equal(sub, translate(sprites[i].Image, e.Rect().Min)) {
+				t.Errorf("sprite %q mismatch", e.Name)
+			}
+		}
+		buf := &bytes.Buffer{}
+		if err := imgutil.WriteSheetCSV(buf, entries); err != nil {
+			t.Errorf("unable to write CSV index; %v", err)
+		}
+	}
+	// Slice the grid layout back into cells.
+	sheet, _, err := imgutil.AssembleSheet(sprites, &imgutil.SheetOptions{Columns: 3, Margin: 1, Spacing: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells, err := imgutil.SliceGrid(sheet, imgutil.GridOptions{CellWidth: 12, CellHeight: 8, Margin: 1, Spacing: 2, SkipEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != len(sprites) {
+		t.Errorf("cell count mismatch; expected %d, got %d", len(sprites), len(cells))
+	}
+	if cells[4].Col != 1 || cells[4].Row != 1 {
+		t.Errorf("cell position mismatch; expected (1, 1), got (%d, %d)", cells[4].Col, cells[4].Row)
+	}
+	if got, want := cells[0].Image.At(1, 1), (color.NRGBA{A: 0xFF}); got != want {
+		t.Errorf("cell pixel mismatch; expected %v, got %v", want, got)
+	}
+}
+
+// translate returns a copy of img with its bounds starting at min.
+func translate(img image.Image, min image.Point) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(b.Sub(b.Min).Add(min))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x-b.Min.X+min.X, y-b.Min.Y+min.Y, img.At(x, y))
+		}
+	}
+	return dst
+}
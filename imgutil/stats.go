@@ -0,0 +1,295 @@
+package imgutil
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+)
+
+// A Histogram holds the number of pixels of each 8-bit value per channel.
+// Colour channels are non-alpha-premultiplied.
+type Histogram struct {
+	// Red, green, blue and alpha channels.
+	R, G, B, A [256]int
+	// Luminance (Rec. 601).
+	Luma [256]int
+}
+
+// NewHistogram returns the per-channel histogram of the image img.
+func NewHistogram(img image.Image) *Histogram {
+	h := &Histogram{}
+	forEachRow(img, func(y int, row []uint8) {
+		for i := 0; i < len(row); i += 4 {
+			p := row[i : i+4]
+			h.R[p[0]]++
+			h.G[p[1]]++
+			h.B[p[2]]++
+			h.A[p[3]]++
+			h.Luma[uint8(luma(p)+0.5)]++
+		}
+	})
+	return h
+}
+
+// Percentile returns the smallest value v of the histogram channel such that at
+// least the fraction p in [0, 1] of pixels have a value <= v.
+func Percentile(channel *[256]int, p float64) uint8 {
+	var total int
+	for _, n := range channel {
+		total += n
+	}
+	limit := int(math.Ceil(p * float64(total)))
+	var acc int
+	for v, n := range channel {
+		acc += n
+		if acc >= limit && acc > 0 {
+			return uint8(v)
+		}
+	}
+	return 0xFF
+}
+
+// ChannelStats holds the mean and standard deviation of each channel of an
+// image, indexed by red, green, blue and alpha. Colour channels are
+// non-alpha-premultiplied 8-bit values.
+type ChannelStats struct {
+	// Mean of each channel.
+	Mean [4]float64
+	// Standard deviation of each channel.
+	StdDev [4]float64
+}
+
+// Stats returns the per-channel mean and standard deviation of the image img.
+func Stats(img image.Image) ChannelStats {
+	var sum, sumSq [4]float64
+	var n int
+	forEachRow(img, func(y int, row []uint8) {
+		for i := 0; i < len(row); i += 4 {
+			for c := range 4 {
+				v := float64(row[i+c])
+				sum[c] += v
+				sumSq[c] += v * v
+			}
+			n++
+		}
+	})
+	var stats ChannelStats
+	if n == 0 {
+		return stats
+	}
+	for c := range 4 {
+		mean := sum[c] / float64(n)
+		stats.Mean[c] = mean
+		stats.StdDev[c] = math.Sqrt(math.Max(0, sumSq[c]/float64(n)-mean*mean))
+	}
+	return stats
+}
+
+// A DominantColor is a representative colour of an image.
+type DominantColor struct {
+	// Colour of the cluster.
+	Color color.NRGBA
+	// Fraction of opaque pixels belonging to the cluster.
+	Fraction float64
+}
+
+// maxKMeansIterations is the maximum number of k-means iterations.
+const maxKMeansIterations = 32
+
+// DominantColors returns up to k dominant colours of the opaque pixels of the
+// image img in order of decreasing fraction, using k-means clustering seeded
+// with a median cut palette.
+func DominantColors(img image.Image, k int) []DominantColor {
+	hist, _ := histogram(img)
+	if k <= 0 || len(hist) == 0 {
+		return nil
+	}
+	// Seed the clusters using median cut.
+	var centers [][3]float64
+	for _, c := range medianCut(hist, k) {
+		centers = append(centers, [3]float64{float64(c.R), float64(c.G), float64(c.B)})
+	}
+	assign := make([]int, len(hist))
+	for iter := 0; iter < maxKMeansIterations; iter++ {
+		changed := false
+		for i, e := range hist {
+			best, bestDist := 0, math.Inf(1)
+			for j, center := range centers {
+				var d float64
+				for c := range 3 {
+					diff := float64(e.c[c]) - center[c]
+					d += diff * diff
+				}
+				if d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if iter > 0 && !changed {
+			break
+		}
+		sums := make([][3]float64, len(centers))
+		counts := make([]int, len(centers))
+		for i, e := range hist {
+			j := assign[i]
+			for c := range 3 {
+				sums[j][c] += float64(e.c[c]) * float64(e.count)
+			}
+			counts[j] += e.count
+		}
+		for j := range centers {
+			if counts[j] == 0 {
+				continue
+			}
+			for c := range 3 {
+				centers[j][c] = sums[j][c] / float64(counts[j])
+			}
+		}
+	}
+	counts := make([]int, len(centers))
+	var total int
+	for i, e := range hist {
+		counts[assign[i]] += e.count
+		total += e.count
+	}
+	var colors []DominantColor
+	for j, center := range centers {
+		if counts[j] == 0 {
+			continue
+		}
+		colors = append(colors, DominantColor{
+			Color: color.NRGBA{
+				R: uint8(math.Round(center[0])),
+				G: uint8(math.Round(center[1])),
+				B: uint8(math.Round(center[2])),
+				A: 0xFF,
+			},
+			Fraction: float64(counts[j]) / float64(total),
+		})
+	}
+	slices.SortStableFunc(colors, func(a, b DominantColor) int {
+		return cmp.Compare(b.Fraction, a.Fraction)
+	})
+	return colors
+}
+
+// AutoContrast returns the image img with its luminance range stretched to the
+// full 8-bit range, ignoring the fraction clip of darkest and brightest pixels.
+// The same mapping is applied to each colour channel, preserving hues.
+func AutoContrast(img image.Image, clip float64) image.Image {
+	h := NewHistogram(img)
+	lut := stretchLUT(Percentile(&h.Luma, clip), Percentile(&h.Luma, 1-clip))
+	return applyLUT(img, [3]*[256]uint8{&lut, &lut, &lut})
+}
+
+// AutoLevels returns the image img with the range of each colour channel
+// stretched to the full 8-bit range independently, ignoring the fraction clip of
+// darkest and brightest values per channel. Unlike AutoContrast, AutoLevels
+// also corrects colour casts.
+func AutoLevels(img image.Image, clip float64) image.Image {
+	h := NewHistogram(img)
+	r := stretchLUT(Percentile(&h.R, clip), Percentile(&h.R, 1-clip))
+	g := stretchLUT(Percentile(&h.G, clip), Percentile(&h.G, 1-clip))
+	b := stretchLUT(Percentile(&h.B, clip), Percentile(&h.B, 1-clip))
+	return applyLUT(img, [3]*[256]uint8{&r, &g, &b})
+}
+
+// Gamma returns the image img with gamma correction applied to each colour
+// channel; gamma > 1 brightens and gamma < 1 darkens the image.
+func Gamma(img image.Image, gamma float64) image.Image {
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = uint8(math.Round(255 * math.Pow(float64(v)/255, 1/gamma)))
+	}
+	return applyLUT(img, [3]*[256]uint8{&lut, &lut, &lut})
+}
+
+// stretchLUT returns a lookup table linearly mapping [lo, hi] to [0, 255].
+func stretchLUT(lo, hi uint8) [256]uint8 {
+	var lut [256]uint8
+	for v := range lut {
+		switch {
+		case hi <= lo:
+			lut[v] = uint8(v)
+		case v <= int(lo):
+			lut[v] = 0
+		case v >= int(hi):
+			lut[v] = 0xFF
+		default:
+			lut[v] = uint8(math.Round(255 * float64(v-int(lo)) / float64(hi-lo)))
+		}
+	}
+	return lut
+}
+
+// applyLUT returns a new image with the given lookup tables applied to the red,
+// green and blue channels of img. The returned image is an *image.Gray if img
+// is an *image.Gray and the lookup tables are identical, and an *image.NRGBA
+// otherwise.
+func applyLUT(img image.Image, luts [3]*[256]uint8) image.Image {
+	b := img.Bounds()
+	if src, ok := img.(*image.Gray); ok && luts[0] == luts[1] && luts[1] == luts[2] {
+		dst := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			si, di := src.PixOffset(b.Min.X, y), dst.PixOffset(b.Min.X, y)
+			for i, v := range src.Pix[si : si+b.Dx()] {
+				dst.Pix[di+i] = luts[0][v]
+			}
+		}
+		return dst
+	}
+	dst := image.NewNRGBA(b)
+	forEachRow(img, func(y int, row []uint8) {
+		di := dst.PixOffset(b.Min.X, y)
+		for i := 0; i < len(row); i += 4 {
+			dst.Pix[di+i] = luts[0][row[i]]
+			dst.Pix[di+i+1] = luts[1][row[i+1]]
+			dst.Pix[di+i+2] = luts[2][row[i+2]]
+			dst.Pix[di+i+3] = row[i+3]
+		}
+	})
+	return dst
+}
+
+// forEachRow calls f for each row of the image img, with the pixels of the row
+// as non-alpha-premultiplied 8-bit RGBA values.
+func forEachRow(img image.Image, f func(y int, row []uint8)) {
+	b := img.Bounds()
+	row := make([]uint8, 4*b.Dx())
+	if src, ok := img.(*image.NRGBA); ok {
+		// Read non-alpha-premultiplied pixels directly, as premultiplying and
+		// unpremultiplying would lose precision of translucent pixels.
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := src.PixOffset(b.Min.X, y)
+			copy(row, src.Pix[i:i+4*b.Dx()])
+			f(y, row)
+		}
+		return
+	}
+	read := rowReader(img, b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		read(y, row)
+		unpremultiply(row)
+		f(y, row)
+	}
+}
+
+// unpremultiply converts the given 8-bit alpha-premultiplied RGBA pixels to
+// non-alpha-premultiplied in place.
+func unpremultiply(row []uint8) {
+	for i := 0; i < len(row); i += 4 {
+		a := uint32(row[i+3])
+		if a == 0 || a == 0xFF {
+			continue
+		}
+		row[i] = uint8(uint32(row[i]) * 0xFF / a)
+		row[i+1] = uint8(uint32(row[i+1]) * 0xFF / a)
+		row[i+2] = uint8(uint32(row[i+2]) * 0xFF / a)
+	}
+}
@@ -0,0 +1,60 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+func TestStats(t *testing.T) {
+	// Low contrast image; three quarters dark red and one quarter grey.
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.NRGBA{R: 0x80, G: 0x40, B: 0x40, A: 0xFF}
+			if x == 3 {
+				c = color.NRGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xFF}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	stats := imgutil.Stats(img)
+	if got, want := stats.Mean[0], float64(3*0x80+0x60)/4; got != want {
+		t.Errorf("red mean mismatch; expected %v, got %v", want, got)
+	}
+	if got := stats.StdDev[3]; got != 0 {
+		t.Errorf("alpha standard deviation mismatch; expected 0, got %v", got)
+	}
+	colors := imgutil.DominantColors(img, 2)
+	if len(colors) != 2 {
+		t.Fatalf("dominant colour count mismatch; expected 2, got %d", len(colors))
+	}
+	if want := (color.NRGBA{R: 0x80, G: 0x40, B: 0x40, A: 0xFF}); colors[0].Color != want || colors[0].Fraction != 0.75 {
+		t.Errorf("dominant colour mismatch; expected %v (0.75), got %v (%v)", want, colors[0].Color, colors[0].Fraction)
+	}
+	levels := imgutil.AutoLevels(img, 0)
+	h := imgutil.NewHistogram(levels)
+	if h.R[0] != 4 || h.R[0xFF] != 12 {
+		t.Errorf("auto levels red histogram mismatch; expected 4 at 0 and 12 at 255, got %d and %d", h.R[0], h.R[0xFF])
+	}
+}
+
+func TestStatsTranslucent(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	c := color.NRGBA{R: 200, G: 100, B: 50, A: 10}
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{c.R, c.G, c.B, c.A})
+	}
+	if want, got := [4]float64{200, 100, 50, 10}, imgutil.Stats(img).Mean; got != want {
+		t.Errorf("mean mismatch; expected %v, got %v", want, got)
+	}
+	dst, ok := imgutil.Gamma(img, 1).(*image.NRGBA)
+	if !ok {
+		t.Fatalf("expected *image.NRGBA")
+	}
+	if got := dst.NRGBAAt(1, 1); got != c {
+		t.Errorf("pixel mismatch; expected %v, got %v", c, got)
+	}
+}
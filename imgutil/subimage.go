@@ -4,7 +4,6 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"log"
 )
 
 // SubImager is an interface that extends the basic image.Image interface with
@@ -17,15 +16,13 @@ type SubImager interface {
 }
 
 // SubFallback returns the provided image.Image as a SubImager. It provides a
-// fallback for images missing the SubImage method.
+// fallback for images missing the SubImage method, which returns a view of the
+// image sharing pixels with the original image.
 func SubFallback(img image.Image) SubImager {
-	sub, ok := img.(SubImager)
-	if !ok {
-		// The SubImage fallback method will have a severe impact on performance.
-		log.Println("imgutil.SubFallback: no SubImage method defined; using fallback.")
-		return &subFallback{Image: img}
+	if sub, ok := img.(SubImager); ok {
+		return sub
 	}
-	return sub
+	return &subFallback{Image: img}
 }
 
 // subFallback provides a fallback SubImage method for images.
@@ -34,11 +31,22 @@ type subFallback struct {
 }
 
 // SubImage returns an image representing the portion of the image src visible
-// through r. The returned value doesn't shares pixels with the original image.
+// through r. The returned value shares pixels with the original image.
 func (src *subFallback) SubImage(r image.Rectangle) image.Image {
-	dstRect := image.Rect(0, 0, r.Dx(), r.Dy())
-	dst := image.NewRGBA(dstRect)
-	draw.Draw(dst, dstRect, src, r.Min, draw.Over)
+	return NewSubImage(src.Image, r.Intersect(src.Bounds()))
+}
+
+// Clone returns a copy of the image img, which doesn't share pixels with the
+// original image. It may be used to materialise a subimage. The returned image
+// has the same bounds as img and the same concrete type as img where possible.
+func Clone(img image.Image) draw.Image {
+	b := img.Bounds()
+	if sub, ok := img.(*subImage); ok {
+		// Use the concrete type of the underlying image.
+		img = sub.Image
+	}
+	dst := newLike(img, b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
 	return dst
 }
 
@@ -74,3 +82,9 @@ func (sub *subImage) At(x, y int) color.Color {
 	}
 	return sub.Image.At(x, y)
 }
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares pixels with the original image.
+func (sub *subImage) SubImage(r image.Rectangle) image.Image {
+	return NewSubImage(sub.Image, r.Intersect(sub.bounds))
+}
@@ -0,0 +1,45 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewkiz/pkg/imgutil"
+)
+
+// opaqueImage wraps an image, hiding its SubImage method.
+type opaqueImage struct {
+	image.Image
+}
+
+func TestSubFallback(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	sub := imgutil.SubFallback(opaqueImage{Image: img}).SubImage(image.Rect(2, 2, 6, 6))
+	if want := image.Rect(2, 2, 6, 6); sub.Bounds() != want {
+		t.Errorf("subimage bounds mismatch; expected %v, got %v", want, sub.Bounds())
+	}
+	// The subimage shares pixels with the original image.
+	c := color.NRGBA{R: 0xFF, A: 0xFF}
+	img.SetNRGBA(3, 3, c)
+	if got := sub.At(3, 3); got != c {
+		t.Errorf("shared pixel mismatch; expected %v, got %v", c, got)
+	}
+	if got := sub.At(1, 1); got != color.Transparent {
+		t.Errorf("expected transparent pixel outside of subimage, got %v", got)
+	}
+	// Nested subimages are clipped to their parent.
+	nested := imgutil.SubFallback(sub).SubImage(image.Rect(0, 0, 4, 4))
+	if want := image.Rect(2, 2, 4, 4); nested.Bounds() != want {
+		t.Errorf("nested subimage bounds mismatch; expected %v, got %v", want, nested.Bounds())
+	}
+	// Clone materialises a copy.
+	clone := imgutil.Clone(sub)
+	if _, ok := clone.(*image.NRGBA); !ok {
+		t.Errorf("expected clone of type *image.NRGBA, got %T", clone)
+	}
+	img.SetNRGBA(3, 3, color.NRGBA{})
+	if got := clone.At(3, 3); got != c {
+		t.Errorf("cloned pixel mismatch; expected %v, got %v", c, got)
+	}
+}
@@ -0,0 +1,73 @@
+package osutil
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriteFileAtomic atomically writes the file specified by path, using the given
+// write function to produce its contents. The contents are written to a
+// temporary file in the same directory, created using mode perm (before umask),
+// which is synced to disk and renamed to path once complete (see CommitFile).
+// The temporary file is removed on failure.
+func WriteFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	f, err := CreateTemp(path, perm)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	if err := write(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return CommitFile(f, path)
+}
+
+// CreateTemp creates a new temporary file in the directory of path, using mode
+// perm (before umask), for atomically replacing path with CommitFile.
+func CreateTemp(path string, perm os.FileMode) (*os.File, error) {
+	dir, name := filepath.Split(path)
+	seed := time.Now().UnixNano()
+	for i := 0; ; i++ {
+		tmpName := "." + name + "." + strconv.FormatInt(seed+int64(i), 36) + ".tmp"
+		f, err := os.OpenFile(filepath.Join(dir, tmpName), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) || i >= 10000 {
+			return nil, errors.WithStack(err)
+		}
+	}
+}
+
+// CommitFile atomically replaces the file specified by path with the file f,
+// which must be in the same directory. The file f is synced to disk, closed
+// and renamed to path. The file f is closed but not removed on failure.
+func CommitFile(f *os.File, path string) error {
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
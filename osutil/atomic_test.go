@@ -0,0 +1,102 @@
+package osutil_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/pkg/osutil"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed write preserves the target and removes the temporary file.
+	errWrite := errors.New("write failed")
+	err := osutil.WriteFileAtomic(path, 0o600, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("error mismatch; expected %v, got %v", errWrite, err)
+	}
+	checkDir(t, dir, map[string]string{"a.txt": "old"})
+
+	// A successful write replaces the target, using the given mode.
+	err = osutil.WriteFileAtomic(path, 0o600, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDir(t, dir, map[string]string{"a.txt": "new"})
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mode mismatch; expected %v, got %v", os.FileMode(0o600), perm)
+	}
+
+	// The directory of the target must exist.
+	err = osutil.WriteFileAtomic(filepath.Join(dir, "missing", "b.txt"), 0o600, func(w io.Writer) error {
+		return nil
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error mismatch; expected %v, got %v", os.ErrNotExist, err)
+	}
+	checkDir(t, dir, map[string]string{"a.txt": "new"})
+}
+
+func TestCommitFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	f, err := osutil.CreateTemp(path, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(f.Name()) != dir {
+		t.Errorf("directory mismatch of temporary file; expected %q, got %q", dir, filepath.Dir(f.Name()))
+	}
+	if _, err := io.WriteString(f, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := osutil.CommitFile(f, path); err != nil {
+		t.Fatal(err)
+	}
+	checkDir(t, dir, map[string]string{"a.txt": "foo"})
+}
+
+// checkDir checks that the directory contains exactly the given files, mapped
+// from name to contents.
+func checkDir(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory entries mismatch; expected %d entries, got %q", len(want), names)
+	}
+	for name, contents := range want {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("unable to read %q; %v", name, err)
+			continue
+		}
+		if string(buf) != contents {
+			t.Errorf("%q: contents mismatch; expected %q, got %q", name, contents, buf)
+		}
+	}
+}